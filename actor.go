@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/BlunterMonk/our_archive/internal/hud"
 	"github.com/BlunterMonk/our_archive/pkg/gfx"
 )
@@ -14,9 +16,34 @@ type Actor struct {
 	emoteAnimation *hud.Animation
 	centerPosition hud.Vec3
 	centerScale    float32
+	headOffset     hud.Vec3 // emote_offset_head from settings.json, from the face to where head emotes go
 
 	Faded      bool
 	Silhouette bool
+	// actors on higher layers are drawn in front, the default comes from settings.json
+	Layer        int
+	defaultLayer int
+
+	// easing between speaking and listening, see Focus
+	focused      bool
+	focusFrom    float32
+	focusChanged time.Duration
+	// set by animations, the tint is applied on top of the speaker highlight
+	Tint    hud.Vec3
+	Opacity float32
+
+	tracks       map[string]*animationTrack
+	trackWaiters map[string][]func()
+}
+
+// actorPose is everything about an actor an animation frame can change
+type actorPose struct {
+	Position hud.Vec3
+	Scale    float32
+	Rotation float32
+	Opacity  float32
+	Tint     hud.Vec3
+	FlipX    bool
 }
 type animation struct {
 	start     *hud.Vec3
@@ -30,6 +57,10 @@ func NewActor(name string) *Actor {
 		name:         name,
 		Sprite:       hud.NewSprite(),
 		emoteOffsets: make(map[string]hud.Vec3),
+		Tint:         hud.Vec3{1, 1, 1},
+		Opacity:      1,
+		tracks:       make(map[string]*animationTrack),
+		trackWaiters: make(map[string][]func()),
 	}
 }
 
@@ -95,3 +126,64 @@ func (s *Actor) SetCenter(x, y, scale float32) {
 func (a *Actor) GetCenter() hud.Vec3 {
 	return a.centerPosition
 }
+
+// Face is where head emotes are drawn, the closest thing to where the character's face is
+func (a *Actor) Face() hud.Vec3 {
+	return a.GetPosition().Sub(a.headOffset)
+}
+
+func (a *Actor) GetPose() actorPose {
+	return actorPose{
+		Position: a.GetPosition(),
+		Scale:    a.GetScale(),
+		Rotation: a.GetRotation(),
+		Opacity:  a.Opacity,
+		Tint:     a.Tint,
+		FlipX:    a.GetFlipX(),
+	}
+}
+
+func (a *Actor) SetPose(p actorPose) {
+	a.SetPosition(p.Position)
+	a.SetScale(p.Scale)
+	a.SetRotation(p.Rotation)
+	a.Opacity = p.Opacity
+	a.Tint = p.Tint
+	a.SetFlipX(p.FlipX)
+}
+
+// addPose moves a pose by the difference between two others, the flip is left alone
+func addPose(p, d actorPose) actorPose {
+	return actorPose{
+		Position: p.Position.Add(d.Position),
+		Scale:    p.Scale + d.Scale,
+		Rotation: p.Rotation + d.Rotation,
+		Opacity:  p.Opacity + d.Opacity,
+		Tint:     p.Tint.Add(d.Tint),
+		FlipX:    p.FlipX,
+	}
+}
+
+// subPose is the difference between two poses, keeping the flip of the first
+func subPose(p, d actorPose) actorPose {
+	return actorPose{
+		Position: p.Position.Sub(d.Position),
+		Scale:    p.Scale - d.Scale,
+		Rotation: p.Rotation - d.Rotation,
+		Opacity:  p.Opacity - d.Opacity,
+		Tint:     p.Tint.Sub(d.Tint),
+		FlipX:    p.FlipX,
+	}
+}
+
+// lerpPose moves from one pose towards another, the flip happens as soon as the move starts
+func lerpPose(from, to actorPose, t float32) actorPose {
+	return actorPose{
+		Position: from.Position.Add(to.Position.Sub(from.Position).Mul(t)),
+		Scale:    from.Scale + (to.Scale-from.Scale)*t,
+		Rotation: from.Rotation + (to.Rotation-from.Rotation)*t,
+		Opacity:  from.Opacity + (to.Opacity-from.Opacity)*t,
+		Tint:     from.Tint.Add(to.Tint.Sub(from.Tint).Mul(t)),
+		FlipX:    to.FlipX,
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// background layers, mid-ground props go between the background and the actors, the foreground over the actors
+const (
+	layerBackground = "bg"
+	layerMidground  = "mid"
+	layerForeground = "fg"
+)
+
+// backgrounds can be any of these, the first one found is used
+var backgroundExtensions = []string{".jpeg", ".jpg", ".png", ".webp", ".bmp", ".gif"}
+
+const bgTransitionDuration = 800 * time.Millisecond
+
+// bgLayer is the image on one layer and the one it's changing from while a transition plays
+type bgLayer struct {
+	name       string
+	previous   string
+	transition string
+	progress   float32
+	tween      *loop.GameLoop
+}
+
+var bgLayers = map[string]*bgLayer{
+	layerBackground: {},
+	layerMidground:  {},
+	layerForeground: {},
+}
+
+// verifyTransition checks a transition from the script, "_" and "none" change the layer straight away
+func verifyTransition(name string) error {
+	if instantTransition(name) || name == "fade" {
+		return nil
+	}
+	for _, kind := range []string{"slide_", "wipe_"} {
+		if strings.HasPrefix(name, kind) {
+			if _, ok := transitionDirection(strings.TrimPrefix(name, kind)); ok {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unknown background transition \"%s\", use _, none, fade, slide_<direction> or wipe_<direction> with left, right, up or down", name)
+}
+
+func instantTransition(name string) bool {
+	return name == "_" || name == "none"
+}
+
+// transitionDirection is the way a slide or wipe moves across the screen
+func transitionDirection(name string) (hud.Vec3, bool) {
+	switch name {
+	case "left":
+		return hud.Vec3{-1, 0, 0}, true
+	case "right":
+		return hud.Vec3{1, 0, 0}, true
+	case "up":
+		return hud.Vec3{0, 1, 0}, true
+	case "down":
+		return hud.Vec3{0, -1, 0}, true
+	}
+	return hud.Vec3{}, false
+}
+
+// changeBackground puts a new image on a layer, "_" empties it, done is called once the transition has finished
+func changeBackground(layer, name, transition string, done func()) {
+	l := bgLayers[layer]
+	if l.tween != nil {
+		l.tween.Stop()
+		l.tween = nil
+	}
+	if name == "_" {
+		name = ""
+	}
+
+	if verifyTransition(transition) != nil || instantTransition(transition) || name == l.name || skipping() {
+		l.name = name
+		l.previous = ""
+		l.transition = ""
+		if done != nil {
+			done()
+		}
+		return
+	}
+
+	l.previous = l.name
+	l.name = name
+	l.transition = transition
+	l.progress = 0
+	ease, _ := loop.GetEase("ease_in_out_quad")
+	if transition == "fade" || strings.HasPrefix(transition, "wipe_") {
+		ease = loop.Linear
+	}
+
+	var elapsed time.Duration
+	var tween *loop.GameLoop
+	tween = loop.New(FRAME_DURATION, func(f float64) int {
+		elapsed += FRAME_DURATION
+		if elapsed >= bgTransitionDuration {
+			return -1
+		}
+		l.progress = float32(ease(float64(elapsed) / float64(bgTransitionDuration)))
+		return 0
+	}, func() {
+		// a transition that was replaced or reset has nothing left to finish
+		if l.tween != tween {
+			return
+		}
+		l.previous = ""
+		l.transition = ""
+		l.tween = nil
+		if done != nil {
+			done()
+		}
+	})
+	l.tween = tween
+	tween.Start()
+}
+
+// resetBackgrounds empties every layer
+func resetBackgrounds() {
+	for _, l := range bgLayers {
+		if l.tween != nil {
+			l.tween.Stop()
+		}
+		*l = bgLayer{}
+	}
+}
+
+// drawBackgrounds draws the layers behind the actors
+func drawBackgrounds() {
+	drawLayer(layerBackground)
+	drawLayer(layerMidground)
+}
+
+// drawForeground draws the layer in front of the actors, still under the dialogue
+func drawForeground() {
+	drawLayer(layerForeground)
+}
+
+func drawLayer(layer string) {
+	l := bgLayers[layer]
+	if l.transition == "" {
+		drawLayerImage(l.name, hud.Vec3{}, 1)
+		return
+	}
+
+	p := l.progress
+	switch {
+	case l.transition == "fade":
+		// a background being replaced stays solid underneath the new one,
+		// one being cleared fades out since nothing covers it, anything over it fades out
+		previousAlpha := 1 - p
+		if layer == layerBackground && l.name != "" {
+			previousAlpha = 1
+		}
+		drawLayerImage(l.previous, hud.Vec3{}, previousAlpha)
+		drawLayerImage(l.name, hud.Vec3{}, p)
+	case strings.HasPrefix(l.transition, "slide_"):
+		// the new image pushes the old one off the screen
+		dir, _ := transitionDirection(strings.TrimPrefix(l.transition, "slide_"))
+		drawLayerImage(l.previous, dir.Mul(2*p), 1)
+		drawLayerImage(l.name, dir.Mul(-2*(1-p)), 1)
+	case strings.HasPrefix(l.transition, "wipe_"):
+		// the new image is uncovered across the screen over the old one
+		dir, _ := transitionDirection(strings.TrimPrefix(l.transition, "wipe_"))
+		drawLayerImage(l.previous, hud.Vec3{}, 1)
+
+		var viewport [4]int32
+		gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+		x, y, w, h := viewport[0], viewport[1], viewport[2], viewport[3]
+		shown := func(size int32) int32 {
+			return int32(float32(size) * p)
+		}
+		switch {
+		case dir.X() > 0:
+			w = shown(w)
+		case dir.X() < 0:
+			x, w = x+w-shown(w), shown(w)
+		case dir.Y() > 0:
+			h = shown(h)
+		default:
+			y, h = y+h-shown(h), shown(h)
+		}
+		gl.Enable(gl.SCISSOR_TEST)
+		gl.Scissor(x, y, w, h)
+		drawLayerImage(l.name, hud.Vec3{}, 1)
+		gl.Disable(gl.SCISSOR_TEST)
+	}
+}
+
+// drawLayerImage draws a background over the whole screen moved by offset, in screen space
+func drawLayerImage(name string, offset hud.Vec3, alpha float32) {
+	bg, ok := Backgrounds[name]
+	if !ok || alpha <= 0 {
+		return
+	}
+	m := hud.NewMat4()
+	m.Translate(offset)
+	m.Transpose()
+	bg.SetAlpha(alpha)
+	DrawSprite(bg, hud.ApplyCamera(m), shaderProgram)
+	bg.SetAlpha(1)
+}
+
+// captureLayers lists what's on the layers other than the background, for saving
+func captureLayers() map[string]string {
+	layers := make(map[string]string)
+	for k, l := range bgLayers {
+		if k != layerBackground && l.name != "" {
+			layers[k] = l.name
+		}
+	}
+	return layers
+}
+
+// restoreLayers puts a save's backgrounds back without any transitions
+func restoreLayers(background string, layers map[string]string) {
+	resetBackgrounds()
+	bgLayers[layerBackground].name = background
+	for k, name := range layers {
+		if l, ok := bgLayers[k]; ok {
+			l.name = name
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	backlogTop        = 40  // highest point on screen the backlog draws to, in pixels
+	backlogBottom     = 640 // where the newest entry ends
+	backlogLineHeight = 30
+	backlogNameHeight = 34
+	backlogTextX      = 160
+	backlogNameX      = 130
+)
+
+// BacklogEntry is a line that has been shown, or a reply that was picked
+type BacklogEntry struct {
+	Speaker string
+	Lines   []string
+	Choice  bool
+}
+
+type backlogText struct {
+	name *hud.Text
+	text *hud.Text
+	y    float32
+}
+
+var (
+	backlog      = make([]BacklogEntry, 0)
+	backlogOpen  bool
+	backlogShade *hud.Sprite
+	// how many entries from the newest one the page is scrolled back by
+	backlogScroll int
+	backlogPage   []backlogText
+)
+
+// recordBacklog adds a shown line to the history with the name displayed at the time
+func recordBacklog(name string, lines []string) {
+	speaker := ""
+	if t, ok := Names[name]; ok && t != nil {
+		speaker = t.GetText()
+	}
+	backlog = append(backlog, BacklogEntry{
+		Speaker: speaker,
+		Lines:   append(make([]string, 0), lines...),
+	})
+}
+
+// recordChoice adds the reply sensei picked to the history
+func recordChoice(text string) {
+	backlog = append(backlog, BacklogEntry{
+		Speaker: "Sensei",
+		Lines:   []string{text},
+		Choice:  true,
+	})
+}
+
+func resetBacklog() {
+	closeBacklog()
+	backlog = make([]BacklogEntry, 0)
+}
+
+func openBacklog() {
+	if len(backlog) == 0 {
+		return
+	}
+	backlogOpen = true
+	backlogScroll = 0
+	buildBacklogPage()
+}
+
+func closeBacklog() {
+	backlogOpen = false
+	releaseBacklogPage()
+}
+
+// scrollBacklog moves the page back in time for positive amounts, closing it when scrolled past the newest line
+func scrollBacklog(amount int) {
+	scroll := backlogScroll + amount
+	if scroll < 0 {
+		closeBacklog()
+		return
+	}
+	if scroll >= len(backlog) {
+		scroll = len(backlog) - 1
+	}
+	if scroll == backlogScroll {
+		return
+	}
+	backlogScroll = scroll
+	buildBacklogPage()
+}
+
+// buildBacklogPage creates text for every entry that fits on screen, newest at the bottom
+func buildBacklogPage() {
+	releaseBacklogPage()
+
+	y := float32(backlogBottom)
+	for i := len(backlog) - 1 - backlogScroll; i >= 0; i-- {
+		entry := backlog[i]
+
+		color := hud.COLOR_WHITE
+		if entry.Choice {
+			color = mgl32.Vec3{0.49, 0.81, 1}
+		}
+		text := hud.NewText(entry.Lines, color, Fonts[fontRegular])
+		text.SetScale(float32(DefaultFontSize))
+		text.Complete()
+
+		height := float32(len(text.Text) * backlogLineHeight)
+		if entry.Speaker != "" {
+			height += backlogNameHeight
+		}
+		if y-height < backlogTop && len(backlogPage) > 0 {
+			text.Release()
+			break
+		}
+		y -= height
+
+		page := backlogText{text: text, y: y}
+		if entry.Speaker != "" {
+			page.name = hud.NewSolidText(entry.Speaker, hud.COLOR_WHITE, Fonts[fontBold])
+			page.name.SetScale(float32(speakerScale) * 0.8)
+		}
+		backlogPage = append(backlogPage, page)
+	}
+}
+
+func releaseBacklogPage() {
+	for _, v := range backlogPage {
+		v.text.Release()
+		if v.name != nil {
+			v.name.Release()
+		}
+	}
+	backlogPage = nil
+}
+
+func drawBacklog(view View) {
+	if !backlogOpen {
+		return
+	}
+
+	shaderProgram.Use()
+	if backlogShade != nil {
+		DrawSprite(backlogShade, hud.NewMat4(), shaderProgram)
+	}
+
+	for _, v := range backlogPage {
+		y := v.y
+		if v.name != nil {
+			DrawText(view, v.name, backlogNameX, y)
+			y += backlogNameHeight
+		}
+		DrawText(view, v.text, backlogTextX, y)
+	}
+}
+
+// backlogKeyCallback handles input while the backlog is open
+func backlogKeyCallback(key glfw.Key) {
+	switch key {
+	case glfw.KeyH, glfw.KeyEscape:
+		closeBacklog()
+	case glfw.KeyUp:
+		scrollBacklog(1)
+	case glfw.KeyDown:
+		scrollBacklog(-1)
+	case glfw.KeyPageUp:
+		scrollBacklog(5)
+	case glfw.KeyPageDown:
+		scrollBacklog(-5)
+	}
+}
+
+func scrollCallback(w *glfw.Window, xoff, yoff float64) {
+	if LOADING || menuScrollWheel(yoff) || PAUSED || editorOpen {
+		return
+	}
+
+	if yoff > 0 {
+		if !backlogOpen {
+			openBacklog()
+		} else {
+			scrollBacklog(1)
+		}
+	} else if yoff < 0 && backlogOpen {
+		scrollBacklog(-1)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+// cameraState is where the camera is looking, the shake is added on top while it lasts
+type cameraState struct {
+	Zoom float32 `json:"zoom"`
+	// point in screen space shown in the middle of the screen
+	Center hud.Vec3 `json:"center"`
+}
+
+// camera moves tween this long with this easing unless settings.json says otherwise
+const (
+	defaultCameraDuration = 0.6
+	defaultCameraEasing   = "ease_in_out_quad"
+)
+
+type cameraShake struct {
+	amount   float32 // how far the screen moves, in screen space
+	duration time.Duration
+}
+
+var cameraShakes = map[string]cameraShake{
+	"light":  {amount: 0.01, duration: 300 * time.Millisecond},
+	"medium": {amount: 0.025, duration: 500 * time.Millisecond},
+	"strong": {amount: 0.05, duration: 800 * time.Millisecond},
+}
+
+var (
+	camera = cameraState{Zoom: 1}
+	// where the camera will be once the current move finishes
+	cameraGoal     = cameraState{Zoom: 1}
+	cameraSettings *script.CameraMetadata
+	cameraTween    *loop.GameLoop
+	cameraShaker   *loop.GameLoop
+	shakeOffset    hud.Vec3
+)
+
+// beginCamera puts the camera on the backgrounds and actors drawn until endCamera,
+// the editor always shows the stage without it so dragging lines up with the mouse
+func beginCamera() {
+	if editorOpen {
+		hud.ClearCamera()
+		return
+	}
+	center := clampCamera(camera.Center, camera.Zoom)
+	hud.SetCamera(camera.Zoom, center.Mul(-camera.Zoom).Add(shakeOffset))
+}
+
+func endCamera() {
+	hud.ClearCamera()
+}
+
+// clampCamera keeps the view inside the background, at a zoom of 1 or less the camera stays in the middle
+func clampCamera(center hud.Vec3, zoom float32) hud.Vec3 {
+	limit := 0.0
+	if zoom > 1 {
+		limit = 1 - 1/float64(zoom)
+	}
+	clamp := func(v float32) float32 {
+		return float32(math.Max(-limit, math.Min(limit, float64(v))))
+	}
+	return hud.Vec3{clamp(center.X()), clamp(center.Y()), 0}
+}
+
+// moveCamera runs a camera command from the script, the script carries on while the camera moves
+func moveCamera(element script.ScriptElement) {
+	target := cameraGoal
+	switch element.Mood {
+	case "zoom":
+		zoom, err := parseZoom(element.Action)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		target.Zoom = zoom
+	case "pan":
+		center, err := cameraTarget(element.Action, target.Center)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		target.Center = center
+	case "shake":
+		shakeCamera(element.Action)
+		return
+	case "reset":
+		target = cameraState{Zoom: 1}
+	default:
+		log.Printf("unknown camera command \"%s\"\n", element.Mood)
+		return
+	}
+	// panning too far is taken back before the move so the tween doesn't stall against the edge
+	target.Center = clampCamera(target.Center, target.Zoom)
+	tweenCamera(target)
+}
+
+// tweenCamera moves the camera to target on the scene clock, replacing any move still going
+func tweenCamera(target cameraState) {
+	cameraGoal = target
+	if cameraTween != nil {
+		cameraTween.Stop()
+		cameraTween = nil
+	}
+
+	duration := time.Duration(defaultCameraDuration * float64(time.Second))
+	ease, _ := loop.GetEase(defaultCameraEasing)
+	if cameraSettings != nil {
+		if cameraSettings.Duration != nil {
+			duration = time.Duration(float64(*cameraSettings.Duration) * float64(time.Second))
+		}
+		if e, ok := loop.GetEase(cameraSettings.Easing); ok && cameraSettings.Easing != "" {
+			ease = e
+		}
+	}
+
+	if skipping() || duration <= 0 {
+		camera = target
+		return
+	}
+
+	start := camera
+	var elapsed time.Duration
+	cameraTween = loop.New(FRAME_DURATION, func(f float64) int {
+		elapsed += FRAME_DURATION
+		progress := 1.0
+		if elapsed < duration {
+			progress = float64(elapsed) / float64(duration)
+		}
+		e := float32(ease(progress))
+		camera = cameraState{
+			Zoom:   start.Zoom + (target.Zoom-start.Zoom)*e,
+			Center: start.Center.Add(target.Center.Sub(start.Center).Mul(e)),
+		}
+		if progress >= 1 {
+			return -1
+		}
+		return 0
+	}, func() {})
+	cameraTween.Start()
+}
+
+// shakeCamera shakes the screen by a named strength or an amount, fading out as it goes
+func shakeCamera(strength string) {
+	shake, err := parseShake(strength)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if cameraShaker != nil {
+		cameraShaker.Stop()
+		cameraShaker = nil
+	}
+	shakeOffset = hud.Vec3{}
+	if skipping() {
+		return
+	}
+
+	var elapsed time.Duration
+	var shaker *loop.GameLoop
+	shaker = loop.New(FRAME_DURATION, func(f float64) int {
+		elapsed += FRAME_DURATION
+		if elapsed >= shake.duration {
+			return -1
+		}
+		// two waves that don't line up so the shake doesn't look like it's going round in circles
+		a := float64(shake.amount) * (1 - float64(elapsed)/float64(shake.duration))
+		t := elapsed.Seconds() * 2 * math.Pi
+		shakeOffset = hud.Vec3{float32(a * math.Sin(t*13)), float32(a * math.Sin(t*17+1.3)), 0}
+		return 0
+	}, func() {
+		if cameraShaker == shaker {
+			shakeOffset = hud.Vec3{}
+			cameraShaker = nil
+		}
+	})
+	cameraShaker = shaker
+	shaker.Start()
+}
+
+// resetCamera puts the camera straight back to the whole stage
+func resetCamera() {
+	if cameraTween != nil {
+		cameraTween.Stop()
+		cameraTween = nil
+	}
+	if cameraShaker != nil {
+		cameraShaker.Stop()
+		cameraShaker = nil
+	}
+	camera = cameraState{Zoom: 1}
+	cameraGoal = camera
+	shakeOffset = hud.Vec3{}
+}
+
+// restoreCamera jumps to a saved camera, saves from before the camera existed show the whole stage
+func restoreCamera(saved *cameraState) {
+	resetCamera()
+	if saved != nil && saved.Zoom > 0 {
+		camera = *saved
+		cameraGoal = camera
+	}
+}
+
+func parseZoom(action string) (float32, error) {
+	zoom, err := strconv.ParseFloat(action, 32)
+	if err != nil || zoom <= 0 {
+		return 0, fmt.Errorf("camera zoom needs a number above 0, not \"%s\"", action)
+	}
+	return float32(zoom), nil
+}
+
+func parseShake(strength string) (cameraShake, error) {
+	if shake, ok := cameraShakes[strength]; ok {
+		return shake, nil
+	}
+	amount, err := strconv.ParseFloat(strength, 32)
+	if err != nil || amount <= 0 {
+		return cameraShake{}, fmt.Errorf("camera shake needs light, medium, strong or an amount, not \"%s\"", strength)
+	}
+	return cameraShake{amount: float32(amount), duration: cameraShakes["medium"].duration}, nil
+}
+
+// cameraTarget works out where a pan goes: a side of the stage, "x y" in screen space or a character's face,
+// sides only change the one direction so left then up ends in the top left
+func cameraTarget(action string, from hud.Vec3) (hud.Vec3, error) {
+	switch action {
+	case "center":
+		return hud.Vec3{}, nil
+	case "left":
+		return hud.Vec3{-1, from.Y(), 0}, nil
+	case "right":
+		return hud.Vec3{1, from.Y(), 0}, nil
+	case "up":
+		return hud.Vec3{from.X(), 1, 0}, nil
+	case "down":
+		return hud.Vec3{from.X(), -1, 0}, nil
+	}
+
+	if fields := strings.Fields(action); len(fields) == 2 {
+		x, errX := strconv.ParseFloat(fields[0], 32)
+		y, errY := strconv.ParseFloat(fields[1], 32)
+		if errX == nil && errY == nil {
+			return hud.Vec3{float32(x), float32(y), 0}, nil
+		}
+	}
+
+	if actor, ok := Actors[action]; ok {
+		return actor.Face(), nil
+	}
+	return from, fmt.Errorf("camera can't pan to \"%s\", use left, right, up, down, center, \"x y\" or a character", action)
+}
+
+// verifyCamera checks a camera line from the script before it's played,
+// names are any subject the script uses since characters aren't loaded yet
+func verifyCamera(element script.ScriptElement, names map[string]bool) error {
+	switch element.Mood {
+	case "zoom":
+		_, err := parseZoom(element.Action)
+		return err
+	case "pan":
+		if names[element.Action] {
+			return nil
+		}
+		_, err := cameraTarget(element.Action, hud.Vec3{})
+		return err
+	case "shake":
+		_, err := parseShake(element.Action)
+		return err
+	case "reset":
+		return nil
+	}
+	return fmt.Errorf("unknown camera command \"%s\", use zoom, pan, shake or reset", element.Mood)
+}
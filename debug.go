@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+var (
+	// set once the emote balloon has been moved with JKLI, until then its offset isn't worth saving
+	balloonMoved bool
+)
+
+// commitDebugSettings writes the speaking character's position and scale into settings.json,
+// along with the balloon offset as the head or bubble offset when the balloon was moved,
+// everything else in the file is kept as it is
+func commitDebugSettings(head bool) error {
+	actor, ok := charSprite[CurrentSpeaker]
+	if !ok {
+		return fmt.Errorf("nobody is speaking, there's no character to save")
+	}
+
+	// the file is read again so edits made by hand since loading aren't lost
+	metadata, err := script.LoadMetadata(settingsFile)
+	// settings in the old format were converted on the way in, saving writes them out in the new one
+	if err != nil && err != script.ErrOldSettings {
+		return fmt.Errorf("settings.json needs to load without problems before it can be saved: %v", err)
+	}
+	if metadata.Actors == nil {
+		metadata.Actors = make(map[string]script.ActorMetadata)
+	}
+
+	// a one-off animation that's still going would be saved part way through
+	for _, t := range actor.findTracks("_") {
+		if !t.metadata.Loop {
+			return fmt.Errorf("%s is still playing \"%s\", wait for it to finish before saving", CurrentSpeaker, t.animation)
+		}
+	}
+
+	settings := metadata.Actors[CurrentSpeaker]
+	// loops move the actor around where they rest, only where they rest is saved
+	resting := actor.RestingPose()
+	// under the auto layout x comes from the actor's slot, center_x is only used when placing them by hand
+	if !layoutAuto {
+		settings.CenterX = roundSetting(resting.Position.X())
+	}
+	settings.CenterY = roundSetting(resting.Position.Y())
+	settings.CenterScale = roundSetting(resting.Scale)
+
+	if balloonMoved {
+		// the balloon is drawn from wherever the actor is right now
+		p := actor.GetPosition()
+		offset := p.Sub(Sprites[spriteEmoteBalloon].GetPosition())
+		position := script.Position{X: roundSetting(offset.X()), Y: roundSetting(offset.Y())}
+		if head {
+			settings.EmoteOffsetHead = position
+		} else {
+			settings.EmoteOffsetBubble = position
+		}
+	}
+	metadata.Actors[CurrentSpeaker] = settings
+
+	err = script.SaveMetadata(settingsFile, metadata)
+	if err != nil {
+		return err
+	}
+
+	// the file watcher picks up the change and applies it like any other edit
+	log.Printf("saved %s to %s: %+v\n", CurrentSpeaker, settingsFile, settings)
+	return nil
+}
+
+// roundSetting drops the noise left over from nudging values by small steps
+func roundSetting(v float32) float32 {
+	return float32(math.Round(float64(v)*1000) / 1000)
+}
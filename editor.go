@@ -0,0 +1,646 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/internal/script"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	editorTextX = 760
+	editorTextY = 90
+	// new animations start at the same speed most of the ones in settings.json use
+	editorDefaultSpeed = 0.1
+)
+
+var (
+	editorOpen bool
+	// working copies of every animation, only the ones in editorChanged are written when saving
+	editorAnimations map[string]script.AnimationMetadata
+	editorChanged    map[string]bool
+	editorActor      string
+	editorAnimation  string
+	editorFrame      int
+	// where the actor was when it was picked, frames are played from here and it's put back on close
+	editorStart actorPose
+	// loops taken off the actor while it's edited, they start again once it's put back
+	editorLoops    map[string]string
+	editorPreview  *loop.GameLoop
+	editorDragging bool
+	editorCursor   [2]float64
+	// set while a name for a new animation is being typed
+	editorNaming bool
+	editorName   string
+	editorAuto   bool
+	editorText   *hud.Text
+	editorMarks  []*hud.Text
+)
+
+// openEditor starts editing the animations of whoever is speaking
+func openEditor() {
+	if LOADING || editorOpen || len(editorActorNames()) == 0 {
+		return
+	}
+
+	editorOpen = true
+	closeBacklog()
+	stopSkip()
+	// auto mode would move the script on while frames are being placed
+	editorAuto = AUTO
+	AUTO = false
+
+	editorAnimations = make(map[string]script.AnimationMetadata)
+	for k, v := range ActorAnimations {
+		v.Frames = append([]script.FrameMetadata(nil), v.Frames...)
+		editorAnimations[k] = v
+	}
+	editorChanged = make(map[string]bool)
+
+	editorActor = ""
+	actor := CurrentSpeaker
+	if _, ok := Actors[actor]; !ok {
+		actor = editorActorNames()[0]
+	}
+	selectEditorActor(actor)
+
+	names := editorAnimationNames()
+	if len(names) > 0 {
+		selectEditorAnimation(names[0])
+	} else {
+		newEditorAnimation("new_animation")
+	}
+}
+
+func closeEditor() {
+	if !editorOpen {
+		return
+	}
+	editorOpen = false
+	editorNaming = false
+	editorDragging = false
+	stopEditorPreview()
+
+	if actor, ok := Actors[editorActor]; ok {
+		actor.SetPose(editorStart)
+		actor.StartLoops(editorLoops)
+	}
+	AUTO = editorAuto
+	releaseEditorText()
+
+	for name := range editorChanged {
+		log.Println("animation wasn't saved:", name)
+	}
+}
+
+// editorActorNames are the loaded actors that can be drawn
+func editorActorNames() []string {
+	names := make([]string, 0, len(Actors))
+	for k, v := range Actors {
+		if v != nil && len(v.GetTextures()) > 0 {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func editorAnimationNames() []string {
+	names := make([]string, 0, len(editorAnimations))
+	for k := range editorAnimations {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectEditorActor puts the last actor back where it was and starts editing from where the new one is standing
+func selectEditorActor(name string) {
+	stopEditorPreview()
+	if actor, ok := Actors[editorActor]; ok {
+		actor.SetPose(editorStart)
+		actor.StartLoops(editorLoops)
+	}
+
+	// anything else moving the actor would fight the frames being placed
+	editorActor = name
+	actor := Actors[name]
+	editorLoops = actor.CancelAnimations()
+	editorStart = actor.GetPose()
+	showEditorFrame()
+}
+
+func selectEditorAnimation(name string) {
+	stopEditorPreview()
+	editorAnimation = name
+	editorFrame = 0
+	if len(editorAnimations[name].Frames) == 0 {
+		editorFrame = -1
+	}
+	showEditorFrame()
+}
+
+// newEditorAnimation adds an animation with a single frame where the actor is standing
+func newEditorAnimation(name string) {
+	if _, ok := editorAnimations[name]; ok {
+		selectEditorAnimation(name)
+		return
+	}
+
+	editorAnimations[name] = script.AnimationMetadata{
+		Speed: editorDefaultSpeed,
+		Frames: []script.FrameMetadata{
+			{X: setting(roundSetting(editorStart.Position.X())), Y: setting(roundSetting(editorStart.Position.Y()))},
+		},
+	}
+	editorChanged[name] = true
+	selectEditorAnimation(name)
+}
+
+// editorFramePose is where the actor ends up after the given frame, played from the start like AsyncAnimateActor would
+func editorFramePose(index int) actorPose {
+	frames := editorAnimations[editorAnimation].Frames
+	center := Actors[editorActor].GetCenter()
+
+	pose := editorStart
+	for i := 0; i <= index && i < len(frames); i++ {
+		pose = frameToTargetPose(frames[i], center, pose, editorStart)
+	}
+	return pose
+}
+
+// showEditorFrame moves the actor to the selected frame and rebuilds the text on screen
+func showEditorFrame() {
+	if actor, ok := Actors[editorActor]; ok && editorPreview == nil {
+		actor.SetPose(editorFramePose(editorFrame))
+	}
+	buildEditorText()
+}
+
+// editFrame changes the selected frame and marks the animation as needing to be saved
+func editFrame(edit func(frame *script.FrameMetadata)) {
+	anim := editorAnimations[editorAnimation]
+	if editorFrame < 0 || editorFrame >= len(anim.Frames) {
+		return
+	}
+	edit(&anim.Frames[editorFrame])
+	editorAnimations[editorAnimation] = anim
+	editorChanged[editorAnimation] = true
+	showEditorFrame()
+}
+
+// moveEditorFrame places the selected frame at a new position, the frame becomes absolute
+// so it no longer depends on where the frames before it end
+func moveEditorFrame(dx, dy float32) {
+	pose := editorFramePose(editorFrame)
+	editFrame(func(frame *script.FrameMetadata) {
+		if frame.Reset || frame.Center {
+			frame.Scale = setting(pose.Scale)
+		}
+		frame.Reset = false
+		frame.Center = false
+		frame.AddX = nil
+		frame.AddY = nil
+		frame.X = setting(roundSetting(pose.Position.X() + dx))
+		frame.Y = setting(roundSetting(pose.Position.Y() + dy))
+	})
+}
+
+func scaleEditorFrame(s float32) {
+	scale := editorFramePose(editorFrame).Scale
+	editFrame(func(frame *script.FrameMetadata) {
+		frame.Scale = setting(roundSetting(scale + s))
+	})
+}
+
+func delayEditorFrame(d float32) {
+	editFrame(func(frame *script.FrameMetadata) {
+		var delay float32
+		if frame.Delay != nil {
+			delay = *frame.Delay
+		}
+		delay = roundSetting(delay + d)
+		if delay <= 0 {
+			frame.Delay = nil
+		} else {
+			frame.Delay = setting(delay)
+		}
+	})
+}
+
+func rotateEditorFrame(r float32) {
+	rotation := editorFramePose(editorFrame).Rotation
+	editFrame(func(frame *script.FrameMetadata) {
+		frame.Rotation = setting(roundSetting(rotation + r))
+	})
+}
+
+func flipEditorFrame() {
+	flip := !editorFramePose(editorFrame).FlipX
+	editFrame(func(frame *script.FrameMetadata) {
+		frame.FlipX = &flip
+	})
+}
+
+// durationEditorFrame changes how long the frame takes, a frame without one goes back to the animation's speed
+func durationEditorFrame(d float32) {
+	editFrame(func(frame *script.FrameMetadata) {
+		var duration float32
+		if frame.Duration != nil {
+			duration = *frame.Duration
+		}
+		duration = roundSetting(duration + d)
+		if duration <= 0 {
+			frame.Duration = nil
+		} else {
+			frame.Duration = setting(duration)
+		}
+	})
+}
+
+// easeEditorFrame moves the frame on to the next easing
+func easeEditorFrame() {
+	editFrame(func(frame *script.FrameMetadata) {
+		names := loop.EaseNames()
+		next := 0
+		for i, v := range names {
+			if v == frame.Easing || (frame.Easing == "" && v == "linear") {
+				next = (i + 1) % len(names)
+			}
+		}
+		frame.Easing = names[next]
+		if frame.Easing == "linear" {
+			frame.Easing = ""
+		}
+	})
+}
+
+// speedEditorAnimation keeps the speed between 0.01 and 1, 1 jumps straight to the first frame
+func speedEditorAnimation(s float32) {
+	anim := editorAnimations[editorAnimation]
+	anim.Speed = roundSetting(anim.Speed + s)
+	if anim.Speed < 0.01 {
+		anim.Speed = 0.01
+	}
+	if anim.Speed > 1 {
+		anim.Speed = 1
+	}
+	editorAnimations[editorAnimation] = anim
+	editorChanged[editorAnimation] = true
+	buildEditorText()
+}
+
+// addEditorFrame adds a frame after the selected one, starting where the selected one ends
+func addEditorFrame() {
+	anim := editorAnimations[editorAnimation]
+	position := editorFramePose(editorFrame).Position
+	frame := script.FrameMetadata{X: setting(roundSetting(position.X())), Y: setting(roundSetting(position.Y()))}
+
+	index := editorFrame + 1
+	frames := make([]script.FrameMetadata, 0, len(anim.Frames)+1)
+	frames = append(frames, anim.Frames[:index]...)
+	frames = append(frames, frame)
+	frames = append(frames, anim.Frames[index:]...)
+	anim.Frames = frames
+
+	editorAnimations[editorAnimation] = anim
+	editorChanged[editorAnimation] = true
+	editorFrame = index
+	showEditorFrame()
+}
+
+func removeEditorFrame() {
+	anim := editorAnimations[editorAnimation]
+	// an animation always needs a frame to play
+	if editorFrame < 0 || len(anim.Frames) <= 1 {
+		return
+	}
+	anim.Frames = append(anim.Frames[:editorFrame:editorFrame], anim.Frames[editorFrame+1:]...)
+
+	editorAnimations[editorAnimation] = anim
+	editorChanged[editorAnimation] = true
+	if editorFrame >= len(anim.Frames) {
+		editorFrame = len(anim.Frames) - 1
+	}
+	showEditorFrame()
+}
+
+// previewEditorAnimation plays the animation from the start the same way the script would
+func previewEditorAnimation() {
+	stopEditorPreview()
+
+	actor := Actors[editorActor]
+	actor.SetPose(editorStart)
+
+	var preview *loop.GameLoop
+	preview = AsyncAnimateActor(actor, editorAnimations[editorAnimation], nil, func() {
+		if editorPreview != preview {
+			return
+		}
+		editorPreview = nil
+		if editorOpen {
+			showEditorFrame()
+		}
+	})
+	editorPreview = preview
+	buildEditorText()
+}
+
+func stopEditorPreview() {
+	if editorPreview == nil {
+		return
+	}
+	preview := editorPreview
+	editorPreview = nil
+	preview.Stop()
+}
+
+// saveEditorAnimations writes every changed animation into settings.json, everything else in the file is kept as it is
+func saveEditorAnimations() error {
+	metadata, err := script.LoadMetadata(settingsFile)
+	// settings in the old format were converted on the way in, saving writes them out in the new one
+	if err != nil && err != script.ErrOldSettings {
+		return fmt.Errorf("settings.json needs to load without problems before it can be saved: %v", err)
+	}
+	if metadata.Animations == nil {
+		metadata.Animations = make(map[string]script.AnimationMetadata)
+	}
+
+	for name := range editorChanged {
+		anim := editorAnimations[name]
+		anim.Name = ""
+		metadata.Animations[name] = anim
+	}
+	err = script.SaveMetadata(settingsFile, metadata)
+	if err != nil {
+		return err
+	}
+
+	for name := range editorChanged {
+		// the editor keeps changing its own copy of the frames
+		anim := editorAnimations[name]
+		anim.Frames = append([]script.FrameMetadata(nil), anim.Frames...)
+		ActorAnimations[name] = anim
+		log.Println("saved animation:", name)
+	}
+	editorChanged = make(map[string]bool)
+	return nil
+}
+
+func buildEditorText() {
+	releaseEditorText()
+
+	anim := editorAnimations[editorAnimation]
+	name := editorAnimation
+	if editorChanged[name] {
+		name += " *"
+	}
+
+	text := []string{
+		fmt.Sprintf("actor: %s", editorActor),
+		fmt.Sprintf("animation: %s", name),
+		fmt.Sprintf("speed: %g", anim.Speed),
+	}
+	if editorFrame >= 0 && editorFrame < len(anim.Frames) {
+		frame := anim.Frames[editorFrame]
+		pose := editorFramePose(editorFrame)
+		text = append(text, fmt.Sprintf("frame %d of %d", editorFrame+1, len(anim.Frames)))
+		text = append(text, fmt.Sprintf("position: (%g, %g)", roundSetting(pose.Position.X()), roundSetting(pose.Position.Y())))
+		text = append(text, fmt.Sprintf("scale: %g", roundSetting(pose.Scale)))
+		if pose.Rotation != 0 {
+			text = append(text, fmt.Sprintf("rotation: %g", roundSetting(pose.Rotation)))
+		}
+		if pose.FlipX {
+			text = append(text, "flipped")
+		}
+		if frame.Duration != nil {
+			text = append(text, fmt.Sprintf("duration: %gs", *frame.Duration))
+		}
+		if frame.Easing != "" {
+			text = append(text, fmt.Sprintf("easing: %s", frame.Easing))
+		}
+		if frame.Delay != nil {
+			text = append(text, fmt.Sprintf("delay: %gs", *frame.Delay))
+		}
+	}
+	if editorPreview != nil {
+		text = append(text, "playing...")
+	}
+	if editorNaming {
+		text = append(text, fmt.Sprintf("new animation: %s_", editorName))
+	}
+	editorText = hud.NewSolidText(strings.Join(text, "\n"), hud.COLOR_WHITE, Fonts[fontRegular])
+	editorText.SetScale(float32(DefaultFontSize))
+
+	for i := range anim.Frames {
+		color := hud.COLOR_WHITE
+		if i == editorFrame {
+			color = mgl32.Vec3{0.49, 0.81, 1}
+		}
+		mark := hud.NewSolidText(fmt.Sprint(i+1), color, Fonts[fontBold])
+		mark.SetScale(float32(DefaultFontSize))
+		editorMarks = append(editorMarks, mark)
+	}
+}
+
+func releaseEditorText() {
+	if editorText != nil {
+		editorText.Release()
+		editorText = nil
+	}
+	for _, v := range editorMarks {
+		v.Release()
+	}
+	editorMarks = nil
+}
+
+// drawEditorActor draws the actor being edited when the script doesn't have them on screen
+func drawEditorActor(proj hud.Mat4) {
+	if !editorOpen {
+		return
+	}
+	if _, ok := charSprite[editorActor]; ok {
+		return
+	}
+	if actor, ok := Actors[editorActor]; ok {
+		actor.Draw(shaderProgram, proj)
+	}
+}
+
+func drawEditor(view View) {
+	if !editorOpen {
+		return
+	}
+
+	// number every frame where it ends so the path can be seen
+	for i, v := range editorMarks {
+		x, y := editorToScreen(view, editorFramePose(i).Position)
+		DrawText(view, v, x, y)
+	}
+	if editorText != nil {
+		DrawText(view, editorText, editorTextX, editorTextY)
+	}
+}
+
+// editorToScreen turns a sprite position into pixels
+func editorToScreen(view View, position hud.Vec3) (float32, float32) {
+	x := (position.X() + 1) / 2 * float32(view.WindowWidth)
+	y := (1 - position.Y()) / 2 * float32(view.WindowHeight)
+	return x, y
+}
+
+// editorKeyCallback handles input while the editor is open
+func editorKeyCallback(key glfw.Key) {
+	if editorNaming {
+		switch key {
+		case glfw.KeyEscape:
+			editorNaming = false
+		case glfw.KeyEnter, glfw.KeyKPEnter:
+			editorNaming = false
+			if editorName != "" {
+				newEditorAnimation(editorName)
+			}
+		case glfw.KeyBackspace:
+			if len(editorName) > 0 {
+				editorName = editorName[:len(editorName)-1]
+			}
+		}
+		buildEditorText()
+		return
+	}
+
+	if key == glfw.KeyEscape {
+		closeEditor()
+		return
+	}
+	// leave the actor alone while it's being played
+	if editorPreview != nil && key != glfw.KeySpace {
+		return
+	}
+
+	switch key {
+	case glfw.KeyPageUp, glfw.KeyPageDown:
+		names := editorActorNames()
+		i := sort.SearchStrings(names, editorActor)
+		if key == glfw.KeyPageUp {
+			i--
+		} else {
+			i++
+		}
+		selectEditorActor(names[(i+len(names))%len(names)])
+	case glfw.KeyUp, glfw.KeyDown:
+		names := editorAnimationNames()
+		i := sort.SearchStrings(names, editorAnimation)
+		if key == glfw.KeyUp {
+			i--
+		} else {
+			i++
+		}
+		selectEditorAnimation(names[(i+len(names))%len(names)])
+	case glfw.KeyLeft:
+		if editorFrame > 0 {
+			editorFrame--
+			showEditorFrame()
+		}
+	case glfw.KeyRight:
+		if editorFrame < len(editorAnimations[editorAnimation].Frames)-1 {
+			editorFrame++
+			showEditorFrame()
+		}
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		addEditorFrame()
+	case glfw.KeyDelete:
+		removeEditorFrame()
+	case glfw.KeyMinus:
+		scaleEditorFrame(-0.01)
+	case glfw.KeyEqual:
+		scaleEditorFrame(0.01)
+	case glfw.KeyLeftBracket:
+		speedEditorAnimation(-0.01)
+	case glfw.KeyRightBracket:
+		speedEditorAnimation(0.01)
+	case glfw.KeySemicolon:
+		delayEditorFrame(-0.1)
+	case glfw.KeyApostrophe:
+		delayEditorFrame(0.1)
+	case glfw.KeyComma:
+		durationEditorFrame(-0.1)
+	case glfw.KeyPeriod:
+		durationEditorFrame(0.1)
+	case glfw.KeyT:
+		easeEditorFrame()
+	case glfw.KeyQ:
+		rotateEditorFrame(-5)
+	case glfw.KeyW:
+		rotateEditorFrame(5)
+	case glfw.KeyF:
+		flipEditorFrame()
+	case glfw.KeySpace:
+		if editorPreview != nil {
+			stopEditorPreview()
+			showEditorFrame()
+		} else {
+			previewEditorAnimation()
+		}
+	case glfw.KeyN:
+		editorNaming = true
+		editorName = ""
+		buildEditorText()
+	case glfw.KeyS:
+		if err := saveEditorAnimations(); err != nil {
+			log.Println("failed to save animations:", err)
+			DEBUG_TEXT = err.Error()
+		} else {
+			DEBUG_TEXT = "saved animations to settings.json"
+		}
+		buildEditorText()
+	}
+}
+
+// editorCharCallback types the name of a new animation, names follow the same rules as resource files
+func editorCharCallback(w *glfw.Window, char rune) {
+	if !editorOpen || !editorNaming {
+		return
+	}
+	if char >= 'A' && char <= 'Z' {
+		char += 'a' - 'A'
+	}
+	if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '_' {
+		editorName += string(char)
+		buildEditorText()
+	}
+}
+
+// editorMouseButton drags the selected frame around
+func editorMouseButton(button glfw.MouseButton, action glfw.Action, x, y float64) {
+	if button != glfw.MouseButtonLeft || editorPreview != nil {
+		return
+	}
+
+	editorDragging = action == glfw.Press
+	editorCursor = [2]float64{x, y}
+}
+
+func editorCursorCallback(w *glfw.Window, x, y float64) {
+	if !editorOpen || !editorDragging {
+		return
+	}
+
+	// pixels to sprite positions, which go from -1 to 1 across the window
+	dx := float32(x-editorCursor[0]) * 2 / float32(CURRENT_VIEW.WindowWidth)
+	dy := float32(editorCursor[1]-y) * 2 / float32(CURRENT_VIEW.WindowHeight)
+	editorCursor = [2]float64{x, y}
+	if dx != 0 || dy != 0 {
+		moveEditorFrame(dx, dy)
+	}
+}
+
+func setting(v float32) *float32 {
+	return &v
+}
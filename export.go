@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/pkg/gfx"
+	"github.com/BlunterMonk/our_archive/pkg/sfx"
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+const (
+	// how long the scene has to sit still before the export clicks to move it on
+	exportIdleClick = time.Second
+	// how long a reply stays on screen before it's picked
+	exportReplyWait = time.Second
+	// how long the last line stays on screen before the export finishes
+	exportEndHold = 2 * time.Second
+)
+
+type exportFrame struct {
+	image    *image.RGBA
+	filename string
+}
+
+// runExport plays a script on a fixed timestep with nobody watching,
+// every frame is written as a png and everything heard is mixed into a single wav file
+func runExport(view View, name string) int {
+	fps := *exportFPS
+	if fps <= 0 {
+		fmt.Println("fps needs to be more than 0")
+		return 1
+	}
+	out := *exportOutput
+	if out == "" {
+		out = filepath.Join("export", name)
+	}
+	err := os.MkdirAll(out, 0755)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// a window is still needed for the OpenGL context, it's hidden since nothing is drawn to it
+	window := gfx.Init(view.WindowWidth, view.WindowHeight)
+	window.Hide()
+	defer gfx.Shutdown()
+	sfx.InitRecording()
+	hud.Init()
+	shaderProgram = gfx.MustInitShader()
+
+	loads, metadata, missing := queueResources(view, name)
+	if len(missing) > 0 {
+		fmt.Println(errorsToString(missing))
+		return 1
+	}
+	for _, v := range loads {
+		err := loadResource(v)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	applyMetadata(metadata)
+
+	fb, err := gfx.NewFramebuffer(view.WindowWidth, view.WindowHeight)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer fb.Delete()
+
+	recorder, err := sfx.NewRecorder(filepath.Join(out, "audio.wav"))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// png encoding is the slowest part, so frames are written in the background
+	frames := make(chan exportFrame, runtime.NumCPU()*2)
+	var writers sync.WaitGroup
+	var writeErr error
+	var writeMtx sync.Mutex
+	for i := 0; i < runtime.NumCPU(); i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for f := range frames {
+				if err := SaveImage(f.image, f.filename); err != nil {
+					writeMtx.Lock()
+					writeErr = err
+					writeMtx.Unlock()
+				}
+			}
+		}()
+	}
+
+	AUTO = true
+	step := time.Second / time.Duration(fps)
+	proj := hud.ProjMatrix(float32(view.WindowWidth), float32(view.WindowHeight))
+	export := exportState{choices: *exportChoices}
+
+	nextDialogue(&status)
+	frame := 0
+	for ; ; frame++ {
+		// run everything the script sent since the last frame
+		for len(status) > 0 {
+			handleStatus(<-status)
+		}
+		if export.finished(step) {
+			break
+		}
+
+		fb.Bind()
+		gl.ClearColor(0.4, 0.4, 0.4, 0.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+		drawExportFrame(view, proj)
+		frames <- exportFrame{
+			image:    fb.ReadImage(),
+			filename: filepath.Join(out, fmt.Sprintf("frame_%06d.png", frame)),
+		}
+		fb.UnBind()
+
+		// audio is taken by sample count so it never drifts from the frames
+		samples := sfx.SampleRate.N(time.Second*time.Duration(frame+1)/time.Duration(fps)) - sfx.SampleRate.N(time.Second*time.Duration(frame)/time.Duration(fps))
+		err = recorder.Record(samples)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+
+		loop.Scene.Advance(step)
+
+		if frame%fps == 0 {
+			log.Printf("exported %d seconds, line %d of %d\n", frame/fps, dialogueIndex+1, len(Script.Elements))
+		}
+	}
+
+	close(frames)
+	writers.Wait()
+	if err := recorder.Close(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if writeErr != nil {
+		fmt.Println(writeErr)
+		return 1
+	}
+
+	fmt.Printf("exported %d frames to %s\n", frame, out)
+	fmt.Println("to make a video run:")
+	fmt.Printf("ffmpeg -framerate %d -i %s -i %s -c:v libx264 -pix_fmt yuv420p -c:a aac -shortest %s.mp4\n",
+		fps, filepath.Join(out, "frame_%06d.png"), filepath.Join(out, "audio.wav"), name)
+	return 0
+}
+
+func drawExportFrame(view View, proj hud.Mat4) {
+	shaderProgram.Use()
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
+	beginCamera()
+	drawBackgrounds()
+	drawActors(proj)
+	drawForeground()
+	endCamera()
+	drawUI(view, proj)
+	drawText(view)
+
+	// re-enable blending to resolve alpha issue
+	shaderProgram.Use()
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
+	drawOverlays()
+}
+
+// exportState stands in for the player, it moves the script on whenever nothing else will
+type exportState struct {
+	choices []int
+	idle    time.Duration
+}
+
+// finished clicks through the script like a player would and is true once the last line has been shown
+func (e *exportState) finished(step time.Duration) bool {
+
+	// replies are picked once they've finished appearing
+	if len(reply) > 0 {
+		for _, v := range reply {
+			if !v.Active || v.IsAnimating() {
+				e.idle = 0
+				return false
+			}
+		}
+		e.idle += step
+		if e.idle >= exportReplyWait {
+			e.idle = 0
+			chooseReply(reply[e.nextChoice(len(reply))])
+		}
+		return false
+	}
+
+	// anything still running on the clock will move the script on by itself, voices are left to finish
+	if WAITING_CONFIRMATION || loop.Scene.Busy() > 0 || voicePlaying() {
+		e.idle = 0
+		return false
+	}
+
+	e.idle += step
+	if dialogueIndex >= len(Script.Elements)-1 {
+		return e.idle >= exportEndHold
+	}
+	if e.idle >= exportIdleClick {
+		e.idle = 0
+		nextDialogue(&status)
+	}
+	return false
+}
+
+// nextChoice returns the index of the reply to pick
+func (e *exportState) nextChoice(count int) int {
+	choice := 0
+	if len(e.choices) > 0 {
+		choice = e.choices[0] - 1
+		e.choices = e.choices[1:]
+	}
+	if choice < 0 || choice >= count {
+		log.Printf("there's no reply %d, picking the first one\n", choice+1)
+		choice = 0
+	}
+	return choice
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+// how long the dim and scale take to catch up when the speaker changes
+const focusDuration = 150 * time.Millisecond
+
+// defaultDim is how much darker everyone who isn't speaking is drawn when settings.json doesn't say
+const defaultDim = 0.3
+
+// speakerDim is how much darker everyone who isn't speaking is drawn, 0 turns dimming off
+func speakerDim() float32 {
+	if speakerSettings == nil || speakerSettings.Dim == nil {
+		return defaultDim
+	}
+	return *speakerSettings.Dim
+}
+
+// speakerGrow is how much bigger the speaker is drawn
+func speakerGrow() float32 {
+	if speakerSettings == nil || speakerSettings.Scale <= 0 {
+		return 1
+	}
+	return speakerSettings.Scale
+}
+
+// Focus is how far the actor has turned into the speaker, from 0 for someone listening to 1 for whoever is speaking,
+// it eases over when they start or stop speaking instead of snapping
+func (a *Actor) Focus(speaking bool) float32 {
+	now := loop.Scene.Now()
+	if speaking != a.focused {
+		a.focusFrom = a.focusAt(now)
+		a.focusChanged = now
+		a.focused = speaking
+	}
+	return a.focusAt(now)
+}
+
+func (a *Actor) focusAt(now time.Duration) float32 {
+	target := float32(0)
+	if a.focused {
+		target = 1
+	}
+	progress := float32(1)
+	// the clock starts again with every script, anything from before then has long finished
+	if elapsed := now - a.focusChanged; elapsed >= 0 && elapsed < focusDuration {
+		progress = float32(elapsed) / float32(focusDuration)
+	}
+	return a.focusFrom + (target-a.focusFrom)*progress
+}
+
+// dimmedTint is the color an actor is drawn with, tints set by animations are left alone so they look the way they were made
+func dimmedTint(actor *Actor, focus float32) hud.Vec3 {
+	if actor.Tint != (hud.Vec3{1, 1, 1}) {
+		return actor.Tint
+	}
+	return actor.Tint.Mul(1 - speakerDim()*(1-focus))
+}
+
+// bounceSpeaker gives whoever starts speaking a little hop, when it's turned on in settings.json
+func bounceSpeaker(actor *Actor) {
+	if speakerSettings == nil || speakerSettings.Bounce <= 0 || skipping() {
+		return
+	}
+	if _, ok := charSprite[actor.name]; !ok {
+		return
+	}
+	// cutting a hop short would leave them in the air, so one that's still going is left to land
+	if len(actor.findTracks(speakTrack)) > 0 {
+		return
+	}
+
+	height := speakerSettings.Bounce
+	actor.PlayAnimation(speakTrack, script.AnimationMetadata{
+		Frames: []script.FrameMetadata{
+			{AddY: setting(height), Duration: setting(0.1), Easing: "ease_out_quad"},
+			{AddY: setting(-height), Duration: setting(0.12), Easing: "ease_in_quad"},
+		},
+		Track: speakTrack,
+	}, nil)
+}
@@ -30,7 +30,8 @@ func (a *Animation) Animate(onFinish func()) {
 	a.isAnimating = true
 	remainingLoops := a.loopCount
 	// log.Println("starting animation with loops:", remainingLoops)
-	a.looper = loop.New(a.data.GetFirstDelay(), func(f float64) int {
+	var looper *loop.GameLoop
+	looper = loop.New(a.data.GetFirstDelay(), func(f float64) int {
 		frame, delay := a.data.GetNextFrame(a.currentFrame)
 		a.currentFrame = frame
 		// log.Printf("animation frame: %d, loop: %d", a.currentFrame, remainingLoops)
@@ -43,9 +44,13 @@ func (a *Animation) Animate(onFinish func()) {
 		return int(delay)
 	}, func() {
 		// log.Println("animation ended")
-		a.isAnimating = false
+		// a stopped animation may have been started again before its loop ended
+		if a.looper == looper {
+			a.isAnimating = false
+		}
 		onFinish()
 	})
+	a.looper = looper
 	a.looper.Start()
 }
 
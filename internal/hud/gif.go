@@ -90,7 +90,7 @@ func (a *AnimatedSprite) GetNextFrame(currentFrame int) (int, time.Duration) {
 }
 
 func (a *AnimatedSprite) DrawFrame(proj Mat4, frame int, position Vec3, shader *gfx.Program) {
-	transform := CalculateTransform(proj, a.Width(), a.Height(), a.scale, position.ToV3())
+	transform := CalculateTransform(proj, a.Width(), a.Height(), a.scale, a.rotation, position.ToV3())
 
 	// draw any previous frames if the disposal is none
 	for i := 0; i < frame; i++ {
@@ -83,7 +83,8 @@ func NewMat4() Mat4 {
 	return (Mat4)(mat4.From(&mat4.Ident))
 }
 
-func CalculateTransform(prj Mat4, width, height, scale float32, position v3.T) Mat4 {
+// CalculateTransform places a sprite of the given size on screen, rotation is in degrees clockwise
+func CalculateTransform(prj Mat4, width, height, scale, rotation float32, position v3.T) Mat4 {
 	// convert parameters into a transform
 	out := mat4.From(&mat4.Ident)
 
@@ -108,14 +109,49 @@ func CalculateTransform(prj Mat4, width, height, scale float32, position v3.T) M
 
 	// apply scale
 	out = *m.AssignMul(&out, &sc)
+	// rotate before the projection stretches the screen, so the sprite keeps its shape while it turns
+	if rotation != 0 {
+		rot := mat4.From(&mat4.Ident)
+		rot.AssignZRotation(-rotation * math.Pi / 180)
+		out = *m.AssignMul(&rot, &out)
+	}
 	// apply projection to 2D
-	out = *m.AssignMul(&out, prj.T())
+	out = *m.AssignMul(prj.T(), &out)
 	// screen space translations need to be normalized
 	out = *m.Translate(&position)
 	// open gl is column major, with translation in the right-most values,
 	// however this math library seems to be row majow, doing a transpose here puts the values in the correct order
 	out = *out.Transpose()
-	return (Mat4)(out)
+	return ApplyCamera((Mat4)(out))
+}
+
+// camera is put on top of every transform while it's set, the UI is drawn after it's cleared
+var camera *mat4.T
+
+// SetCamera zooms everything drawn until ClearCamera around the center of the screen,
+// then moves it by offset, both in screen space
+func SetCamera(zoom float32, offset Vec3) {
+	c := mat4.From(&mat4.Ident)
+	c.ScaleVec3(&v3.T{zoom, zoom, 1})
+	c[3][0] = offset.X()
+	c[3][1] = offset.Y()
+	camera = &c
+}
+
+func ClearCamera() {
+	camera = nil
+}
+
+// ApplyCamera moves a finished transform by the camera, if there is one
+func ApplyCamera(m Mat4) Mat4 {
+	if camera == nil {
+		return m
+	}
+	// transforms are kept transposed for open gl, undo that to multiply and put it back after
+	t := m.T().Transposed()
+	out := mat4.From(&mat4.Ident)
+	out.AssignMul(camera, &t)
+	return (Mat4)(*out.Transpose())
 }
 
 func loadGif(filename string) *gif.GIF {
@@ -44,6 +44,8 @@ type Sprite struct {
 	scale         float32
 	overlayColor  Vec3
 	position      Vec3
+	rotation      float32 // degrees, positive turns clockwise
+	flipX         bool
 }
 
 func NewSprite() *Sprite {
@@ -141,6 +143,22 @@ func (s *Sprite) GetScale() float32 {
 	return s.scale
 }
 
+// SetRotation turns the sprite around its center, in degrees clockwise
+func (s *Sprite) SetRotation(degrees float32) {
+	s.rotation = degrees
+}
+func (s *Sprite) GetRotation() float32 {
+	return s.rotation
+}
+
+// SetFlipX mirrors the sprite so it faces the other way
+func (s *Sprite) SetFlipX(flip bool) {
+	s.flipX = flip
+}
+func (s *Sprite) GetFlipX() bool {
+	return s.flipX
+}
+
 func (s *Sprite) SetPositionf(x, y, z float32) {
 	s.position = Vec3{x, y, z}
 }
@@ -153,6 +171,10 @@ func (s *Sprite) GetPosition() Vec3 {
 	return s.position
 }
 
+func (s *Sprite) GetActiveTextureKey() string {
+	return s.activeTexture
+}
+
 func (s *Sprite) SetActiveTexture(key string) error {
 	if _, ok := s.textures[key]; !ok {
 		return fmt.Errorf("texture doesn't exist: %s", key)
@@ -191,6 +213,11 @@ func (s *Sprite) drawTexture(m Mat4, shader *gfx.Program, texture *gfx.Texture)
 	mat := m.Slice()
 	gl.UniformMatrix4fv(shader.GetUniformLocation("prjMatrix"), 1, false, &mat[0])
 	gl.Uniform4f(shader.GetUniformLocation("overlayColor"), s.overlayColor.X(), s.overlayColor.Y(), s.overlayColor.Z(), s.alpha)
+	var flip int32
+	if s.flipX {
+		flip = 1
+	}
+	gl.Uniform1i(shader.GetUniformLocation("flipX"), flip)
 
 	// draw vertices
 	gl.BindVertexArray(vbo)
@@ -201,7 +228,7 @@ func (s *Sprite) drawTexture(m Mat4, shader *gfx.Program, texture *gfx.Texture)
 }
 
 func (s *Sprite) GetTransform(proj Mat4) Mat4 {
-	return CalculateTransform(proj, s.Width(), s.Height(), s.scale, s.position.ToV3())
+	return CalculateTransform(proj, s.Width(), s.Height(), s.scale, s.rotation, s.position.ToV3())
 }
 
 func (s *Sprite) Translate(x, y, z float32) {
@@ -7,6 +7,7 @@ import (
 	"unicode"
 
 	v41 "github.com/4ydx/gltext/v4.1"
+	"github.com/BlunterMonk/our_archive/internal/loop"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -162,6 +163,17 @@ func (s *Text) Done() bool {
 	return s.done
 }
 
+// Complete shows all of the text at once without the typewriter effect
+func (s *Text) Complete() {
+	copy(s.Output, s.Text)
+	s.done = true
+}
+
+// GetText returns the full text, lines that were wrapped are joined back together
+func (s *Text) GetText() string {
+	return strings.Join(s.Text, " ")
+}
+
 // func (s *Text) Complete() {
 // 	s.done = false
 
@@ -199,53 +211,50 @@ func (s *Text) Done() bool {
 // 	return nil
 // }
 
-// AsyncAnimate - Asynchronous function used to animate text
+// AsyncAnimate - types the text out on the scene clock, status gets 1 a second after the last character
 func (s *Text) AsyncAnimate(status *chan uint32) {
 	// TODO: this is setup this way in case more animations are added
-	go animateTypewriter(s, status)
+	animateTypewriter(s, status).Start()
 }
 
-func animateTypewriter(s *Text, status *chan uint32) error {
+func animateTypewriter(s *Text, status *chan uint32) *loop.GameLoop {
 	s.done = false
 
-	// how fast the text should display
-	tick := time.Tick(32 * time.Millisecond)
-
 	lines := s.Text
 	output := &s.Output
 
-	// chop up the string
-	lineCount := len(lines)
-
-	for index := 0; index < lineCount; index++ {
-		line := lines[index]
-		runes := []rune(line)
-		count := len(runes)
-		displayed := make([]rune, 0)
-		c := 0
+	// how much of the text has been displayed
+	index := 0
+	displayed := 0
+	typed := false
 
-		s.TextObjects[index].Show()
-
-		for len(displayed) != count {
-			select {
-			case <-tick:
-
-				displayed = append(displayed, runes[c])
-				c++
+	// how fast the text should display
+	return loop.New(32*time.Millisecond, func(f float64) int {
+		if typed {
+			return -1
+		}
 
-				// return string to source
-				(*output)[index] = string(displayed)
-				break
-			}
+		// move past lines that are fully displayed
+		for index < len(lines) && displayed >= len([]rune(lines[index])) {
+			index++
+			displayed = 0
+		}
+		if index >= len(lines) {
+			// wait a second before finishing
+			typed = true
+			return int(time.Second)
 		}
-	}
 
-	sec := time.NewTimer(time.Second)
-	<-sec.C
+		runes := []rune(lines[index])
+		displayed++
 
-	s.done = true
-	*status <- 1 // send status update to listening channel
-	return nil
+		// return string to source
+		(*output)[index] = string(runes[:displayed])
+		return 0
+	}, func() {
+		s.done = true
+		*status <- 1 // send status update to listening channel
+	})
 }
 
 // wrapString wraps the given string within lim width in characters.
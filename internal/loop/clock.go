@@ -0,0 +1,176 @@
+package loop
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStep is how far the scene clock moves for each update
+	DefaultStep = 16 * time.Millisecond
+	// the most real time a single Update will catch up on, so a long stall doesn't fast forward the scene
+	maxCatchUp = 250 * time.Millisecond
+)
+
+// Clock is the time every loop runs on, it only moves forward when Advance is called
+// so the main thread decides how fast the scene plays and loops never run on their own goroutine
+type Clock struct {
+	mtx   sync.Mutex
+	now   time.Duration
+	loops []*GameLoop
+
+	step        time.Duration // fixed amount the clock moves by on Update
+	speed       float64
+	paused      bool
+	accumulator time.Duration // real time that hasn't been turned into steps yet
+	steps       int           // single steps queued while paused
+}
+
+// Scene is the clock loops run on once they're started
+var Scene = NewClock(DefaultStep)
+
+func NewClock(step time.Duration) *Clock {
+	return &Clock{
+		loops: make([]*GameLoop, 0),
+		step:  step,
+		speed: 1,
+	}
+}
+
+// Update turns the real time that passed into fixed steps, scaled by the speed,
+// it returns how many steps were run
+func (c *Clock) Update(elapsed time.Duration) int {
+	c.mtx.Lock()
+	if elapsed > maxCatchUp {
+		elapsed = maxCatchUp
+	}
+	steps := c.steps
+	c.steps = 0
+	if !c.paused {
+		c.accumulator += time.Duration(float64(elapsed) * c.speed)
+		steps += int(c.accumulator / c.step)
+		c.accumulator %= c.step
+	}
+	step := c.step
+	c.mtx.Unlock()
+
+	for i := 0; i < steps; i++ {
+		c.Advance(step)
+	}
+	return steps
+}
+
+// SetSpeed changes how fast the scene plays, 1 is normal speed
+func (c *Clock) SetSpeed(speed float64) {
+	if speed <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.speed = speed
+}
+
+func (c *Clock) Speed() float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.speed
+}
+
+// Pause stops Update from moving the clock, Advance and Step still work
+func (c *Clock) Pause() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.paused = true
+	c.accumulator = 0
+}
+
+func (c *Clock) Resume() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.paused = false
+}
+
+func (c *Clock) IsPaused() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.paused
+}
+
+// Step queues a single step for the next Update, used to move through the scene a frame at a time while paused
+func (c *Clock) Step() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.steps++
+}
+
+// Now returns how much time has passed on the clock
+func (c *Clock) Now() time.Duration {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// Len returns how many loops are still running
+func (c *Clock) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.loops)
+}
+
+// Busy returns how many loops are running that will end on their own
+func (c *Clock) Busy() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	busy := 0
+	for _, g := range c.loops {
+		if !g.endless {
+			busy++
+		}
+	}
+	return busy
+}
+
+// Add starts running a loop, its first update happens once a full tick has passed
+func (c *Clock) Add(g *GameLoop) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.loops = append(c.loops, g)
+}
+
+// Advance moves the clock forward and runs every update that was due in that time,
+// loops started by those updates wait for the next call
+func (c *Clock) Advance(dt time.Duration) {
+	c.mtx.Lock()
+	c.now += dt
+	loops := append(make([]*GameLoop, 0, len(c.loops)), c.loops...)
+	c.mtx.Unlock()
+
+	finished := make(map[*GameLoop]bool)
+	for _, g := range loops {
+		if g.step(dt) {
+			finished[g] = true
+		}
+	}
+	if len(finished) == 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	running := c.loops[:0]
+	for _, g := range c.loops {
+		if !finished[g] {
+			running = append(running, g)
+		}
+	}
+	c.loops = running
+	c.mtx.Unlock()
+}
+
+// After calls fn once d has passed on the scene clock
+func After(d time.Duration, fn func()) *GameLoop {
+	g := New(d, func(f float64) int {
+		return -1
+	}, fn)
+	g.Start()
+	return g
+}
@@ -0,0 +1,130 @@
+package loop
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		speed   float64
+		elapsed []time.Duration
+		steps   int
+	}{
+		{"one step", 1, []time.Duration{DefaultStep}, 1},
+		{"less than a step", 1, []time.Duration{DefaultStep - 1}, 0},
+		{"leftover carries over", 1, []time.Duration{10 * time.Millisecond, 10 * time.Millisecond}, 1},
+		{"several steps at once", 1, []time.Duration{3 * DefaultStep}, 3},
+		{"double speed", 2, []time.Duration{DefaultStep}, 2},
+		{"half speed", 0.5, []time.Duration{DefaultStep, DefaultStep}, 1},
+		{"long stall is capped", 1, []time.Duration{10 * time.Second}, int(maxCatchUp / DefaultStep)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClock(DefaultStep)
+			c.SetSpeed(tt.speed)
+			steps := 0
+			for _, e := range tt.elapsed {
+				steps += c.Update(e)
+			}
+			if steps != tt.steps {
+				t.Errorf("got %d steps, want %d", steps, tt.steps)
+			}
+			if want := DefaultStep * time.Duration(tt.steps); c.Now() != want {
+				t.Errorf("clock is at %v, want %v", c.Now(), want)
+			}
+		})
+	}
+}
+
+func TestClockPauseAndStep(t *testing.T) {
+	c := NewClock(DefaultStep)
+	c.Update(DefaultStep / 2)
+	c.Pause()
+	if steps := c.Update(time.Second); steps != 0 {
+		t.Fatalf("paused clock ran %d steps", steps)
+	}
+
+	c.Step()
+	c.Step()
+	if steps := c.Update(0); steps != 2 {
+		t.Fatalf("got %d queued steps, want 2", steps)
+	}
+
+	// time left over from before the pause is dropped
+	c.Resume()
+	if steps := c.Update(DefaultStep / 2); steps != 0 {
+		t.Fatalf("got %d steps after resuming, want 0", steps)
+	}
+}
+
+func TestClockSetSpeedIgnoresZero(t *testing.T) {
+	c := NewClock(DefaultStep)
+	c.SetSpeed(0)
+	c.SetSpeed(-1)
+	if c.Speed() != 1 {
+		t.Errorf("speed is %g, want 1", c.Speed())
+	}
+}
+
+func TestClockAdvance(t *testing.T) {
+	c := NewClock(DefaultStep)
+	updates := 0
+	stopped := false
+	c.Add(New(10*time.Millisecond, func(f float64) int {
+		updates++
+		if updates == 3 {
+			return -1
+		}
+		return 0
+	}, func() {
+		stopped = true
+	}))
+
+	c.Advance(25 * time.Millisecond)
+	if updates != 2 || stopped {
+		t.Fatalf("after 25ms got %d updates, stopped %v, want 2 and still running", updates, stopped)
+	}
+	c.Advance(5 * time.Millisecond)
+	if updates != 3 || !stopped {
+		t.Fatalf("after 30ms got %d updates, stopped %v, want 3 and stopped", updates, stopped)
+	}
+	if c.Len() != 0 {
+		t.Errorf("%d loops left on the clock, want 0", c.Len())
+	}
+}
+
+func TestClockAdvanceNewTickRate(t *testing.T) {
+	c := NewClock(DefaultStep)
+	updates := 0
+	c.Add(New(10*time.Millisecond, func(f float64) int {
+		updates++
+		// wait a second before the next update
+		return int(time.Second)
+	}, func() {}))
+
+	c.Advance(100 * time.Millisecond)
+	if updates != 1 {
+		t.Fatalf("got %d updates, want 1", updates)
+	}
+	c.Advance(time.Second)
+	if updates != 2 {
+		t.Fatalf("got %d updates, want 2", updates)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	called := false
+	After(50*time.Millisecond, func() {
+		called = true
+	})
+	Scene.Advance(40 * time.Millisecond)
+	if called {
+		t.Fatal("called before the time was up")
+	}
+	Scene.Advance(10 * time.Millisecond)
+	if !called {
+		t.Fatal("not called once the time was up")
+	}
+}
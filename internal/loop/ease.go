@@ -0,0 +1,117 @@
+package loop
+
+import (
+	"math"
+	"sort"
+)
+
+// Ease turns how far through a tween is, from 0 to 1, into how far the value has moved,
+// curves like back and elastic go past 1 on their way to the end
+type Ease func(t float64) float64
+
+// Linear moves at the same rate the whole way
+func Linear(t float64) float64 {
+	return t
+}
+
+var eases = map[string]Ease{
+	"linear":              Linear,
+	"ease_in_quad":        easeInQuad,
+	"ease_out_quad":       out(easeInQuad),
+	"ease_in_out_quad":    inOut(easeInQuad),
+	"ease_in_cubic":       easeInCubic,
+	"ease_out_cubic":      out(easeInCubic),
+	"ease_in_out_cubic":   inOut(easeInCubic),
+	"ease_in_back":        easeInBack,
+	"ease_out_back":       out(easeInBack),
+	"ease_in_out_back":    inOut(easeInBack),
+	"ease_in_bounce":      out(easeOutBounce),
+	"ease_out_bounce":     easeOutBounce,
+	"ease_in_out_bounce":  inOut(out(easeOutBounce)),
+	"ease_in_elastic":     easeInElastic,
+	"ease_out_elastic":    out(easeInElastic),
+	"ease_in_out_elastic": inOut(easeInElastic),
+}
+
+// GetEase finds an easing by the name used in settings.json, no name is linear
+func GetEase(name string) (Ease, bool) {
+	if name == "" {
+		return Linear, true
+	}
+	e, ok := eases[name]
+	return e, ok
+}
+
+// EaseNames lists every easing that can be used
+func EaseNames() []string {
+	names := make([]string, 0, len(eases))
+	for k := range eases {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SpeedFrames is how many frames an animation that moves speed of the way there every frame takes,
+// the small tolerance stops speeds like 0.1 rounding up to an extra frame
+func SpeedFrames(speed float64) int {
+	if speed <= 0 {
+		return 0
+	}
+	return int(math.Ceil(1/speed - 0.001))
+}
+
+// out plays an ease-in curve backwards so it slows down at the end instead of the start
+func out(in Ease) Ease {
+	return func(t float64) float64 {
+		return 1 - in(1-t)
+	}
+}
+
+// inOut uses the ease-in curve for the first half and its reverse for the second
+func inOut(in Ease) Ease {
+	return func(t float64) float64 {
+		if t < 0.5 {
+			return in(t*2) / 2
+		}
+		return 1 - in((1-t)*2)/2
+	}
+}
+
+func easeInQuad(t float64) float64 {
+	return t * t
+}
+
+func easeInCubic(t float64) float64 {
+	return t * t * t
+}
+
+func easeInBack(t float64) float64 {
+	const overshoot = 1.70158
+	return t * t * ((overshoot+1)*t - overshoot)
+}
+
+func easeOutBounce(t float64) float64 {
+	const n = 7.5625
+	const d = 2.75
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	default:
+		t -= 2.625 / d
+		return n*t*t + 0.984375
+	}
+}
+
+func easeInElastic(t float64) float64 {
+	if t <= 0 || t >= 1 {
+		return t
+	}
+	return -math.Pow(2, 10*t-10) * math.Sin((t*10-10.75)*(2*math.Pi)/3)
+}
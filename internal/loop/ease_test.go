@@ -0,0 +1,94 @@
+package loop
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const tolerance = 1e-9
+
+func TestEaseEnds(t *testing.T) {
+	for _, name := range EaseNames() {
+		e, _ := GetEase(name)
+		if got := e(0); math.Abs(got) > tolerance {
+			t.Errorf("%s(0) = %g, want 0", name, got)
+		}
+		if got := e(1); math.Abs(got-1) > tolerance {
+			t.Errorf("%s(1) = %g, want 1", name, got)
+		}
+	}
+}
+
+func TestEaseInOutMirror(t *testing.T) {
+	for _, name := range EaseNames() {
+		if !strings.HasPrefix(name, "ease_in_") || strings.HasPrefix(name, "ease_in_out_") {
+			continue
+		}
+		curve := strings.TrimPrefix(name, "ease_in_")
+		in, _ := GetEase(name)
+		outEase, ok := GetEase("ease_out_" + curve)
+		if !ok {
+			t.Errorf("%s has no ease_out_%s", name, curve)
+			continue
+		}
+		inOutEase, ok := GetEase("ease_in_out_" + curve)
+		if !ok {
+			t.Errorf("%s has no ease_in_out_%s", name, curve)
+			continue
+		}
+
+		for i := 0; i <= 20; i++ {
+			x := float64(i) / 20
+			// the out curve is the in curve played backwards
+			if got, want := outEase(x), 1-in(1-x); math.Abs(got-want) > tolerance {
+				t.Errorf("ease_out_%s(%g) = %g, want %g", curve, x, got, want)
+			}
+			// both halves of in out are the same shape turned around
+			if got := inOutEase(x) + inOutEase(1-x); math.Abs(got-1) > tolerance {
+				t.Errorf("ease_in_out_%s(%g) + ease_in_out_%s(%g) = %g, want 1", curve, x, curve, 1-x, got)
+			}
+		}
+	}
+}
+
+func TestGetEase(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"", true},
+		{"linear", true},
+		{"ease_in_out_cubic", true},
+		{"ease_sideways", false},
+	}
+	for _, tt := range tests {
+		if _, ok := GetEase(tt.name); ok != tt.ok {
+			t.Errorf("GetEase(%q) found %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+}
+
+func TestSpeedFrames(t *testing.T) {
+	tests := []struct {
+		speed  float64
+		frames int
+	}{
+		{0, 0},
+		{-0.5, 0},
+		{1, 1},
+		{0.5, 2},
+		{0.25, 4},
+		{0.1, 10},
+		{0.3, 4},
+		{0.05, 20},
+		{0.01, 100},
+		{float64(float32(0.1)), 10},
+		{float64(float32(0.05)), 20},
+	}
+	for _, tt := range tests {
+		if got := SpeedFrames(tt.speed); got != tt.frames {
+			t.Errorf("SpeedFrames(%g) = %d, want %d", tt.speed, got, tt.frames)
+		}
+	}
+}
@@ -3,7 +3,6 @@
 package loop
 
 import (
-	"runtime"
 	"time"
 )
 
@@ -12,7 +11,10 @@ type GameLoop struct {
 	onUpdate func(float64) int // update function called by loop
 	onStop   func()
 	tickRate time.Duration // tick interval
-	Quit     chan bool     // channel used for exiting the loop
+	elapsed  time.Duration // time passed since the last update
+	running  bool          // set while the loop is on a clock
+	stopped  bool          // set by Stop, the loop ends on the next step of its clock
+	endless  bool          // set for loops that only end when they're stopped
 }
 
 // Create new game loop
@@ -21,59 +23,72 @@ func New(tickRate time.Duration, onUpdate func(float64) int, onStop func()) *Gam
 		onUpdate: onUpdate,
 		onStop:   onStop,
 		tickRate: tickRate,
-		Quit:     make(chan bool),
 	}
 }
 
-// startLoop sets up and runs the loop until we exit.
-func (g *GameLoop) startLoop() {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	// Set up ticker.
-	t := time.NewTicker(g.tickRate)
+// step runs every update that fits in dt, it returns true once the loop has stopped
+func (g *GameLoop) step(dt time.Duration) bool {
+	if g.stopped {
+		g.finish()
+		return true
+	}
 
-	var now int64
-	var delta float64
-	start := time.Now().UnixNano()
-F:
-	for {
-		select {
-		case <-t.C:
-			// Calculate delta T in fractions of seconds.
-			now = time.Now().UnixNano()
-			delta = float64(now-start) / 1000000000
-			start = now
-			code := g.onUpdate(delta)
-			if code == -1 {
-				// fmt.Println("stopping loop")
-				t.Stop()
-				// g.Stop()
-				break F
-			} else if code > 0 {
-				// fmt.Println("resetting ticker:", code)
-				t.Reset(time.Duration(code))
-			}
-			// case <-g.Quit:
-			// t.Stop()
+	g.elapsed += dt
+	for g.elapsed >= g.tickRate {
+		g.elapsed -= g.tickRate
+		code := g.onUpdate(g.tickRate.Seconds())
+		if code == -1 {
+			// fmt.Println("stopping loop")
+			g.finish()
+			return true
+		} else if code > 0 {
+			// fmt.Println("resetting ticker:", code)
+			g.tickRate = time.Duration(code)
+		}
+		// a loop without a tick rate updates once per step
+		if g.tickRate <= 0 {
+			g.elapsed = 0
+			break
 		}
 	}
+	return false
+}
 
+func (g *GameLoop) finish() {
+	g.running = false
+	g.stopped = false
 	g.onStop()
 }
 
-// Start game loop.
+// Start game loop on the scene clock.
 func (g *GameLoop) Start() {
-	go g.startLoop()
+	if g.running {
+		return
+	}
+	g.running = true
+	Scene.Add(g)
 }
 
-// Stop game loop.
+// Stop game loop, onStop is called on the next step of the clock.
 func (g *GameLoop) Stop() {
-	g.Quit <- true
+	if g.running {
+		g.stopped = true
+	}
+}
+
+// SetEndless marks a loop that keeps going until it's stopped, so the clock doesn't count it as work left to do.
+func (g *GameLoop) SetEndless(endless bool) {
+	g.endless = endless
+}
+
+// IsRunning is true from Start until the loop has stopped.
+func (g *GameLoop) IsRunning() bool {
+	return g.running
 }
 
-// Restart game loop.
+// Restart game loop, the next update is a full tick away.
 func (g *GameLoop) Restart() {
-	g.Stop()
+	g.stopped = false
+	g.elapsed = 0
 	g.Start()
 }
@@ -0,0 +1,67 @@
+package script
+
+import (
+	"regexp"
+	"strings"
+)
+
+// a reply option can continue from a label, format: "reply text" -> label_name
+var choiceTargetFormat = regexp.MustCompile(`^(.*\S)\s*->\s*([a-zA-Z0-9_]+)$`)
+
+// Choice is a single reply option shown for a [sensei - _ - _] marker
+type Choice struct {
+	Text   string // text shown on the reply button
+	Target string // label the script continues from, empty to continue with the next line
+}
+
+// Choices returns the reply options of the element, one per line of dialogue
+func (s *ScriptElement) Choices() []Choice {
+	choices := make([]Choice, 0, len(s.Lines))
+	for _, v := range s.Lines {
+		if v == "" {
+			continue
+		}
+		choices = append(choices, parseChoice(v))
+	}
+	return choices
+}
+
+func parseChoice(line string) Choice {
+	values := choiceTargetFormat.FindStringSubmatch(line)
+	if values == nil {
+		return Choice{Text: line}
+	}
+	return Choice{Text: values[1], Target: strings.ToLower(values[2])}
+}
+
+// FindLabel returns the index of the [label - _ - <name>] element
+func (s *Script) FindLabel(name string) (int, bool) {
+	index, ok := s.Labels[name]
+	return index, ok
+}
+
+// indexLabels records where every label is, duplicates are reported and the first one is kept
+func (s *Script) indexLabels() {
+	s.Labels = make(map[string]int)
+	for i, v := range s.Elements {
+		if v.Name != "label" {
+			continue
+		}
+		if v.Action == "_" {
+			s.errorf(v.LineNumber, 0, "[label] marker is missing a name, expected [label - _ - <name>]")
+			continue
+		}
+		if first, ok := s.Labels[v.Action]; ok {
+			s.errorf(v.LineNumber, 0, "label \"%s\" is already defined on line %d", v.Action, s.Elements[first].LineNumber)
+			continue
+		}
+		s.Labels[v.Action] = i
+	}
+}
+
+// checkTarget reports jumps to labels that don't exist
+func (s *Script) checkTarget(line int, target string) {
+	if _, ok := s.Labels[target]; !ok {
+		s.errorf(line, 0, "jump to unknown label \"%s\"", target)
+	}
+}
@@ -0,0 +1,186 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// subjects that control the scene instead of an actor
+var sceneSubjects = map[string]bool{
+	"bg":     true,
+	"bgm":    true,
+	"sfx":    true,
+	"fade":   true,
+	"font":   true,
+	"delay":  true,
+	"clear":  true,
+	"none":   true,
+	"clone":  true,
+	"defect": true,
+	"emote":  true,
+	"label":  true,
+	"jump":   true,
+	"set":    true,
+	"if":     true,
+	"else":   true,
+	"endif":  true,
+	"camera": true,
+	"mid":    true,
+	"fg":     true,
+	"layout": true,
+}
+
+// actor categories that are actions rather than an expression
+var actorActions = map[string]bool{
+	"fade":       true,
+	"full":       true,
+	"silhouette": true,
+	"rename":     true,
+	"defect":     true,
+	"emote":      true,
+	"animation":  true,
+	"stop":       true,
+	"wait":       true,
+	"layer":      true,
+	"_":          true,
+}
+
+// IsSceneSubject is true for subjects that are commands instead of actors
+func IsSceneSubject(name string) bool {
+	return sceneSubjects[name]
+}
+
+// IsActorAction is true when an actor's category is an action instead of an expression
+func IsActorAction(mood string) bool {
+	return actorActions[mood]
+}
+
+func (s *Script) errorf(line, column int, format string, args ...interface{}) {
+	s.report(SeverityError, line, column, format, args...)
+}
+
+func (s *Script) warnf(line, column int, format string, args ...interface{}) {
+	s.report(SeverityWarning, line, column, format, args...)
+}
+
+func (s *Script) report(severity Severity, line, column int, format string, args ...interface{}) {
+	s.Diagnostics = append(s.Diagnostics, Diagnostic{
+		File:     s.Filename,
+		Line:     line,
+		Column:   column,
+		Message:  fmt.Sprintf(format, args...),
+		Severity: severity,
+	})
+}
+
+// check looks for markers that parsed fine but can't do anything when played
+func (s *Script) check() {
+
+	// actors only get a sprite when they are given an expression somewhere in the script
+	hasSprite := make(map[string]bool)
+	for _, v := range s.Elements {
+		switch {
+		case v.Name == "clone":
+			hasSprite[v.Action] = true
+		case !IsSceneSubject(v.Name) && !IsActorAction(v.Mood):
+			hasSprite[v.Name] = true
+		}
+	}
+
+	for _, v := range s.Elements {
+		line := v.LineNumber
+		switch v.Name {
+		case "sfx":
+			if v.Mood == "_" {
+				s.errorf(line, 0, "[sfx] marker is missing the name of the file to use")
+			}
+		case "bgm":
+			switch v.Mood {
+			case "_":
+				s.errorf(line, 0, "[bgm] marker is missing an action or track name")
+			case "play":
+				if v.Action == "_" {
+					s.errorf(line, 0, "[bgm - play] is missing the track name")
+				}
+			case "fade":
+				s.checkInOut(v)
+			}
+		case "fade":
+			if v.Mood != "black" && v.Mood != "white" && v.Mood != "_" {
+				s.warnf(line, 0, "unknown fade color \"%s\", black will be used", v.Mood)
+			}
+			s.checkInOut(v)
+		case "font":
+			if v.Mood != "size" {
+				s.warnf(line, 0, "unknown font setting \"%s\", only size is supported, the marker is ignored", v.Mood)
+			} else if _, err := strconv.ParseFloat(v.Action, 64); err != nil && v.Action != "reset" {
+				s.errorf(line, 0, "font size \"%s\" is not a number or reset", v.Action)
+			}
+		case "delay":
+			if _, err := strconv.ParseFloat(v.Action, 64); err != nil {
+				s.warnf(line, 0, "delay \"%s\" is not a number, 0.5 seconds will be used", v.Action)
+			}
+		case "clone":
+			if v.Mood == "_" || v.Action == "_" {
+				s.errorf(line, 0, "[clone - <actor> - <new_name>] needs both an actor and a new name")
+			}
+		case "defect":
+			if !hasSprite[v.Mood] {
+				s.warnf(line, 0, "unknown subject \"%s\", it never appears in the script", v.Mood)
+			}
+		case "jump":
+			if v.Action == "_" {
+				s.errorf(line, 0, "[jump] marker is missing a label, expected [jump - _ - <label>]")
+			} else {
+				s.checkTarget(line, v.Action)
+			}
+		case "set", "if":
+			s.checkState(v)
+		case "sensei":
+			s.checkReply(v)
+		}
+
+		if IsSceneSubject(v.Name) && v.Name != "defect" && v.HasDialogue() {
+			s.warnf(line+1, 0, "dialogue after a [%s] marker is never shown", v.Name)
+		}
+
+		if IsSceneSubject(v.Name) || v.Name == "all" || v.Name == "sensei" {
+			continue
+		}
+
+		// actor markers
+		switch v.Mood {
+		case "fade":
+			s.checkInOut(v)
+		case "rename":
+			if v.Action == "_" {
+				s.warnf(line, 0, "[%s - rename] has no new name", v.Name)
+			}
+		}
+		if v.Mood != "_" && v.Mood != "rename" && v.Mood != "defect" && !hasSprite[v.Name] {
+			s.warnf(line, 0, "unknown subject \"%s\", it is never given an expression so it has no sprite to %s", v.Name, v.Mood)
+		}
+	}
+}
+
+func (s *Script) checkInOut(v ScriptElement) {
+	if v.Action != "in" && v.Action != "out" {
+		s.errorf(v.LineNumber, 0, "[%s - %s] needs to be either in or out, got \"%s\"", v.Name, v.Mood, v.Action)
+	}
+}
+
+func (s *Script) checkReply(v ScriptElement) {
+	if len(v.Lines) == 0 {
+		s.errorf(v.LineNumber, 0, "[sensei] marker has no reply options")
+		return
+	}
+	for i, option := range v.Lines {
+		if strings.Count(option, "\"")%2 != 0 {
+			s.warnf(v.LineNumber+i+1, strings.LastIndex(option, "\"")+1, "unterminated quote in reply option")
+		}
+		if choice := parseChoice(option); choice.Target != "" {
+			s.checkTarget(v.LineNumber+i+1, choice.Target)
+		}
+	}
+}
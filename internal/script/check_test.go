@@ -0,0 +1,133 @@
+package script
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		message  string
+		severity Severity
+		line     int
+		column   int
+	}{
+		{
+			name:     "marker missing a field",
+			lines:    []string{"[sfx - ]"},
+			message:  "malformed marker [sfx - ], expected [subject - category - action]",
+			severity: SeverityError,
+			line:     1,
+			column:   1,
+		},
+		{
+			name:     "marker with a bad subject",
+			lines:    []string{"[bg - _ - _]", "  [b@d - _ - _]"},
+			message:  "invalid subject \"b@d\"",
+			severity: SeverityError,
+			line:     2,
+			column:   4,
+		},
+		{
+			name:     "marker with a bad category",
+			lines:    []string{"[bg - bad! - _]"},
+			message:  "invalid category \"bad!\"",
+			severity: SeverityError,
+			line:     1,
+			column:   7,
+		},
+		{
+			name:     "unterminated marker",
+			lines:    []string{"[bg - x - _"},
+			message:  "unterminated marker",
+			severity: SeverityError,
+			line:     1,
+			column:   12,
+		},
+		{
+			name:     "dialogue before any marker",
+			lines:    []string{"", "  hello", "[bg - x - _]"},
+			message:  "dialogue found before any [subject - category - action] marker",
+			severity: SeverityError,
+			line:     2,
+			column:   3,
+		},
+		{
+			name:     "dialogue after a scene marker",
+			lines:    []string{"[bg - x - _]", "hello"},
+			message:  "dialogue after a [bg] marker is never shown",
+			severity: SeverityWarning,
+			line:     2,
+		},
+		{
+			name:     "unknown subject",
+			lines:    []string{"[mika - fade - in]"},
+			message:  "unknown subject \"mika\"",
+			severity: SeverityWarning,
+			line:     1,
+		},
+		{
+			name:     "unknown defect subject",
+			lines:    []string{"[defect - mika - Tea Party]"},
+			message:  "unknown subject \"mika\"",
+			severity: SeverityWarning,
+			line:     1,
+		},
+		{
+			name:     "unterminated quote in a reply",
+			lines:    []string{"[sensei - _ - _]", "go \"there"},
+			message:  "unterminated quote in reply option",
+			severity: SeverityWarning,
+			line:     2,
+			column:   4,
+		},
+		{
+			name:     "unterminated if block",
+			lines:    []string{"[if - a - _]", "[_ - _ - _]", "hello"},
+			message:  "unterminated [if] block",
+			severity: SeverityError,
+			line:     1,
+		},
+		{
+			name:     "delay that isn't a number",
+			lines:    []string{"[delay - _ - soon]"},
+			message:  "delay \"soon\" is not a number, 0.5 seconds will be used",
+			severity: SeverityWarning,
+			line:     1,
+		},
+	}
+	for _, tt := range tests {
+		s, _ := ParseScript(tt.name, strings.NewReader(strings.Join(tt.lines, "\n")))
+		var found *Diagnostic
+		for i, v := range s.Diagnostics {
+			if strings.Contains(v.Message, tt.message) {
+				found = &s.Diagnostics[i]
+				break
+			}
+		}
+		if found == nil {
+			t.Errorf("%s: no diagnostic containing %q, got %v", tt.name, tt.message, s.Diagnostics)
+			continue
+		}
+		if found.Severity != tt.severity || found.Line != tt.line || found.Column != tt.column {
+			t.Errorf("%s: got %s, want %s at %d:%d", tt.name, found, tt.severity, tt.line, tt.column)
+		}
+	}
+}
+
+func TestDiagnosticsError(t *testing.T) {
+	_, err := ParseScript("warnings", strings.NewReader("[delay - _ - soon]"))
+	if err != nil {
+		t.Errorf("warnings alone returned an error: %v", err)
+	}
+
+	s, err := ParseScript("errors", strings.NewReader("[sfx - ]\n[bg - x - _]"))
+	if _, ok := err.(Diagnostics); !ok {
+		t.Fatalf("got error %v, want Diagnostics", err)
+	}
+	if len(s.Elements) != 1 || s.Elements[0].Name != "bg" {
+		t.Errorf("got elements %v, want only the [bg] marker", s.Elements)
+	}
+}
@@ -0,0 +1,81 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "unknown"
+}
+
+// Diagnostic is a single problem found while reading a script
+// lines and columns both start at 1, a column of 0 means the whole line
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Message  string
+	Severity Severity
+}
+
+func (d Diagnostic) String() string {
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, d.Message)
+}
+
+// Diagnostics is every problem found in a script, it doubles as the error returned by LoadScript
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	texts := make([]string, 0, len(d))
+	for _, v := range d {
+		texts = append(texts, v.String())
+	}
+	return strings.Join(texts, "\n")
+}
+
+// HasErrors is true when at least one diagnostic is an error rather than a warning
+func (d Diagnostics) HasErrors() bool {
+	for _, v := range d {
+		if v.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns only the diagnostics with an error severity
+func (d Diagnostics) Errors() Diagnostics {
+	return d.filter(SeverityError)
+}
+
+// Warnings returns only the diagnostics with a warning severity
+func (d Diagnostics) Warnings() Diagnostics {
+	return d.filter(SeverityWarning)
+}
+
+func (d Diagnostics) filter(severity Severity) Diagnostics {
+	out := make(Diagnostics, 0)
+	for _, v := range d {
+		if v.Severity == severity {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -4,32 +4,47 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 )
 
 const (
-	ScriptMarkerRegexFormat = `^\[([?a-zA-Z0-9_]+)\s-\s([a-zA-Z0-9_]+)\s-\s([_a-zA-Z0-9-*'\s".?!]+)\]$`
+	ScriptMarkerRegexFormat = `^\[([?a-zA-Z0-9_]+)\s-\s([a-zA-Z0-9_]+)\s-\s([_a-zA-Z0-9-*'\s".?!+<>=]+)\]$`
 	// format: [subject - category - action]
 	// ScriptMarkerRegexFormat = `^\[([a-zA-Z0-9]+)\s-\s([a-z0-9]+)\s-\s([a-z]+)\]$`
 )
 
+// the individual fields of ScriptMarkerRegexFormat, used to point at the broken part of a marker
+var markerFieldFormat = []*regexp.Regexp{
+	regexp.MustCompile(`^[?a-zA-Z0-9_]+$`),
+	regexp.MustCompile(`^[a-zA-Z0-9_]+$`),
+	regexp.MustCompile(`^[_a-zA-Z0-9-*'\s".?!+<>=]+$`),
+}
+
 type Script struct {
-	Elements []ScriptElement
+	Filename    string
+	Elements    []ScriptElement
+	Labels      map[string]int // element index of every [label - _ - <name>]
+	Blocks      map[int]int    // element index of every [if] and [else] to the index where its block ends
+	Diagnostics Diagnostics    // every problem found while parsing, including warnings
 }
 
 // [name - mood - action]
 type ScriptElement struct {
-	Index  int
-	Name   string // name of speaker
-	Mood   string // variant of facial expression that is used
-	Action string // used when emoticons/special expressions are shown
-	Line   string // text for line
-	Lines  []string
+	Index      int
+	LineNumber int    // line of the marker in the script file
+	Name       string // name of speaker
+	Mood       string // variant of facial expression that is used
+	Action     string // used when emoticons/special expressions are shown
+	Line       string // text for line
+	Lines      []string
+	Voice      string // clip played with the line, from a [voice - <clip> - _] marker under it
 }
 
 type Metadata struct {
@@ -39,6 +54,8 @@ type Metadata struct {
 	ActorOld     []ActorMetadata              `json:"actor,omitempty"`
 	AnimationOld []AnimationMetadata          `json:"animation,omitempty"`
 	EmoteOld     []EmoteMetadata              `json:"emote,omitempty"`
+	Camera       *CameraMetadata              `json:"camera,omitempty"`
+	Speaker      *SpeakerMetadata             `json:"speaker,omitempty"`
 }
 type ActorMetadata struct {
 	Name              string   `json:"name,omitempty"`
@@ -48,11 +65,21 @@ type ActorMetadata struct {
 	CenterScale       float32  `json:"center_scale,omitempty"`
 	EmoteOffsetHead   Position `json:"emote_offset_head,omitempty"`
 	EmoteOffsetBubble Position `json:"emote_offset_bubble,omitempty"`
+	// actors on higher layers are drawn in front of lower ones
+	Layer int `json:"layer,omitempty"`
 }
 type AnimationMetadata struct {
 	Name   string          `json:"name,omitempty"`
 	Speed  float32         `json:"speed"`
 	Frames []FrameMetadata `json:"frames"`
+	// animations on different tracks play at the same time, starting one on a busy track replaces what's playing there
+	Track string `json:"track,omitempty"`
+	// looping animations play until they're stopped and never hold up the script
+	Loop bool `json:"loop,omitempty"`
+	// false lets the script carry on while the animation plays
+	Wait *bool `json:"wait,omitempty"`
+	// animation to play on the same track once this one ends
+	Next string `json:"next,omitempty"`
 }
 type FrameMetadata struct {
 	X      *float32 `json:"x,omitempty"`
@@ -63,6 +90,32 @@ type FrameMetadata struct {
 	Delay  *float32 `json:"delay,omitempty"`
 	Reset  bool     `json:"reset,omitempty"`
 	Center bool     `json:"center,omitempty"`
+	// how long the frame takes in seconds, frames without one move at the animation's speed
+	Duration *float32 `json:"duration,omitempty"`
+	Easing   string   `json:"easing,omitempty"`
+	Rotation *float32 `json:"rotation,omitempty"` // degrees, positive turns clockwise
+	Alpha    *float32 `json:"alpha,omitempty"`
+	FlipX    *bool    `json:"flip_x,omitempty"`
+	// red, green and blue from 0 to 1 the sprite is multiplied by
+	Tint *[3]float32 `json:"tint,omitempty"`
+}
+
+// CameraMetadata changes how camera moves in the script tween, leaving it out keeps the defaults
+type CameraMetadata struct {
+	Duration *float32 `json:"duration,omitempty"` // seconds
+	Easing   string   `json:"easing,omitempty"`
+}
+
+// SpeakerMetadata changes how whoever is speaking stands out from everyone else
+type SpeakerMetadata struct {
+	// false leaves the speaker on their own layer instead of drawing them in front of everyone on it
+	OnTop *bool `json:"on_top,omitempty"`
+	// how much darker everyone else is drawn from 0 to 1, 0 turns it off and leaving it out is 0.3
+	Dim *float32 `json:"dim,omitempty"`
+	// how much bigger the speaker is drawn, 1.05 is 5% bigger
+	Scale float32 `json:"scale,omitempty"`
+	// how high the speaker hops when they start a line, leaving it out turns it off
+	Bounce float32 `json:"bounce,omitempty"`
 }
 type EmoteMetadata struct {
 	Name  string  `json:"name,omitempty"`
@@ -74,68 +127,160 @@ type Position struct {
 	Y float32 `json:"y"`
 }
 
-func NewScriptFromFile(filename string) *Script {
-	e := LoadScript(filename)
-	return &Script{
-		Elements: e,
-	}
+func NewScriptFromFile(filename string) (*Script, error) {
+	return LoadScript(filename)
 }
 
-func LoadScript(filename string) []ScriptElement {
+// LoadScript reads and parses the script at filename.
+// the returned script is usable even when the error is a list of Diagnostics,
+// any line that couldn't be understood is left out of the elements
+func LoadScript(filename string) (*Script, error) {
 
 	f, err := os.Open(filename)
 	if err != nil {
-		log.Fatal(err)
+		return nil, errors.Wrap(err, "failed to open script")
 	}
 	defer f.Close()
 
+	return ParseScript(filename, f)
+}
+
+// ParseScript parses a script from r, filename is only used to label diagnostics
+func ParseScript(filename string, r io.Reader) (*Script, error) {
+
 	// Compile the expression once, usually at init time.
 	// Use raw strings to avoid having to quote the backslashes.
 	var validID = regexp.MustCompile(ScriptMarkerRegexFormat)
 
-	lines := make([]ScriptElement, 0)
+	s := &Script{
+		Filename:    filename,
+		Elements:    make([]ScriptElement, 0),
+		Diagnostics: make(Diagnostics, 0),
+	}
 
 	index := -1
-	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		row := strings.Trim(scanner.Text(), " ")
+		lineNumber++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		row := strings.Trim(text, " ")
+		column := strings.Index(text, row) + 1
+
 		match := validID.MatchString(row)
 		if match {
-			index++
-			// log.Printf("Dialogue Count[%v]: %v\n", index, row)
-
 			values := validID.FindAllStringSubmatch(row, -1)
 			name := strings.ToLower(values[0][1])
 			category := strings.ToLower(values[0][2])
 
+			// voices belong to the marker above them instead of being a line of their own
+			if name == "voice" {
+				s.attachVoice(index, lineNumber, category)
+				continue
+			}
+
+			index++
+			// log.Printf("Dialogue Count[%v]: %v\n", index, row)
+
 			action := values[0][3]
 			if name != "defect" && category != "defect" {
 				action = strings.ToLower(action)
 			}
 
-			lines = append(lines, ScriptElement{
-				Index:  index,
-				Name:   name,
-				Mood:   category,
-				Action: action,
-				Lines:  make([]string, 0),
+			s.Elements = append(s.Elements, ScriptElement{
+				Index:      index,
+				LineNumber: lineNumber,
+				Name:       name,
+				Mood:       category,
+				Action:     action,
+				Lines:      make([]string, 0),
 			})
 
+		} else if strings.HasPrefix(row, "[") {
+			// anything that looks like a marker but isn't one would otherwise be read out as dialogue
+			col, msg := describeMalformedMarker(row)
+			s.errorf(lineNumber, column+col, "%s", msg)
+		} else if index < 0 {
+			// blank lines at the top of the file are harmless, text is not
+			if row != "" {
+				s.errorf(lineNumber, column, "dialogue found before any [subject - category - action] marker, there is nobody to say it")
+			}
 		} else {
 			// log.Printf("Dialogue Text[%v]: %v\n", index, row)
 
-			lines[index].Line = lines[index].Line + row
-			lines[index].Lines = append(lines[index].Lines, row)
+			s.Elements[index].Line = s.Elements[index].Line + row
+			s.Elements[index].Lines = append(s.Elements[index].Lines, row)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+		return s, errors.Wrapf(err, "failed to read script: %s", filename)
+	}
+
+	s.indexLabels()
+	s.indexBlocks()
+	s.check()
+	sort.SliceStable(s.Diagnostics, func(i, j int) bool {
+		return s.Diagnostics[i].Line < s.Diagnostics[j].Line
+	})
+	if s.Diagnostics.HasErrors() {
+		return s, s.Diagnostics
 	}
 
-	return lines
+	return s, nil
+}
+
+// attachVoice gives the element at index a voice clip
+func (s *Script) attachVoice(index, line int, clip string) {
+	if index < 0 {
+		s.errorf(line, 0, "[voice] marker found before any [subject - category - action] marker, there is no line for it to go with")
+		return
+	}
+	v := &s.Elements[index]
+	if IsSceneSubject(v.Name) || v.Name == "sensei" {
+		s.errorf(line, 0, "[voice] marker needs to come right after the marker of whoever is speaking, not a [%s] marker", v.Name)
+		return
+	}
+	if clip == "_" {
+		s.errorf(line, 0, "[voice] marker is missing a clip, expected [voice - <clip> - _]")
+		return
+	}
+	if v.Voice != "" {
+		s.warnf(line, 0, "line already has the voice \"%s\", only \"%s\" will be played", v.Voice, clip)
+	}
+	v.Voice = clip
 }
 
+// describeMalformedMarker works out why a row starting with "[" didn't match the marker format
+// the returned column is relative to the start of the row and starts at 0
+func describeMalformedMarker(row string) (int, string) {
+	if !strings.HasSuffix(row, "]") {
+		return len(row), "unterminated marker, expected a closing \"]\""
+	}
+
+	fields := strings.SplitN(row[1:len(row)-1], " - ", 3)
+	if len(fields) < 3 {
+		return 0, fmt.Sprintf("malformed marker %s, expected [subject - category - action]", row)
+	}
+
+	offset := 1
+	for i, name := range []string{"subject", "category", "action"} {
+		if !markerFieldFormat[i].MatchString(fields[i]) {
+			return offset, fmt.Sprintf("malformed marker %s, invalid %s \"%s\"", row, name, fields[i])
+		}
+		offset += len(fields[i]) + len(" - ")
+	}
+
+	return 0, fmt.Sprintf("malformed marker %s, expected [subject - category - action]", row)
+}
+
+// OldSettingsFile is where settings in the old format are written out once they're converted
+const OldSettingsFile = "./resources/settings-new.json"
+
+// ErrOldSettings is returned along with the converted settings when the file was in the old format,
+// the settings can still be used, it only means settings.json should be updated
+var ErrOldSettings = fmt.Errorf("old settings format detected, Arona converted it for you and saved it to: %s", OldSettingsFile)
+
 func LoadMetadata(filename string) (*Metadata, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -148,8 +293,8 @@ func LoadMetadata(filename string) (*Metadata, error) {
 		return nil, err
 	}
 
-	newFile := "./resources/settings-new.json"
-	newFileErr := fmt.Errorf("old settings format detected, Arona converted it for you and saved it to: %s", newFile)
+	converted := false
+	duplicates := make([]string, 0)
 	actorOld := make([]ActorMetadata, 0)
 	animOld := make([]AnimationMetadata, 0)
 	emoteOld := make([]EmoteMetadata, 0)
@@ -164,10 +309,10 @@ func LoadMetadata(filename string) (*Metadata, error) {
 				meta.Actors[n] = v
 			} else {
 				actorOld = append(actorOld, v)
-				err = errors.Wrapf(err, "duplicate actor setting: \"%s\"", v.Name)
+				duplicates = append(duplicates, fmt.Sprintf("duplicate actor setting: \"%s\"", v.Name))
 			}
 		}
-		err = newFileErr
+		converted = true
 	}
 	if len(meta.EmoteOld) > 0 {
 		if meta.Emotes == nil {
@@ -180,10 +325,10 @@ func LoadMetadata(filename string) (*Metadata, error) {
 				meta.Emotes[n] = v
 			} else {
 				emoteOld = append(emoteOld, v)
-				err = errors.Wrapf(err, "duplicate emote setting: \"%s\"", v.Name)
+				duplicates = append(duplicates, fmt.Sprintf("duplicate emote setting: \"%s\"", v.Name))
 			}
 		}
-		err = newFileErr
+		converted = true
 	}
 	if len(meta.AnimationOld) > 0 {
 		if meta.Animations == nil {
@@ -196,38 +341,108 @@ func LoadMetadata(filename string) (*Metadata, error) {
 				meta.Animations[n] = v
 			} else {
 				animOld = append(animOld, v)
-				err = errors.Wrapf(err, "duplicate animation setting: \"%s\"", v.Name)
+				duplicates = append(duplicates, fmt.Sprintf("duplicate animation setting: \"%s\"", v.Name))
 			}
 		}
-		err = newFileErr
-	}
-	if err != nil { // if we get an error here it's because there were some script cleanup to do
-		newMeta := Metadata{
-			Actors:       meta.Actors,
-			Animations:   meta.Animations,
-			Emotes:       meta.Emotes,
-			ActorOld:     actorOld,
-			EmoteOld:     emoteOld,
-			AnimationOld: animOld,
-		}
+		converted = true
+	}
+	if !converted {
+		return &meta, nil
+	}
 
-		newData, err := json.MarshalIndent(newMeta, "", "	")
-		if err != nil {
-			return &meta, errors.Wrap(err, "tried to save the new settings file but something happened")
-		}
-		err = os.WriteFile(newFile, newData, 0777)
-		if err != nil {
-			return &meta, errors.Wrap(err, "tried to save the new settings file but something happened")
-		}
+	// whatever couldn't be converted is kept so saving doesn't lose it
+	meta.ActorOld = actorOld
+	meta.EmoteOld = emoteOld
+	meta.AnimationOld = animOld
+
+	newData, err := json.MarshalIndent(meta, "", "	")
+	if err != nil {
+		return &meta, errors.Wrap(err, "tried to save the new settings file but something happened")
+	}
+	err = os.WriteFile(OldSettingsFile, newData, 0777)
+	if err != nil {
+		return &meta, errors.Wrap(err, "tried to save the new settings file but something happened")
 	}
 
-	return &meta, err
+	if len(duplicates) > 0 {
+		return &meta, fmt.Errorf("%s\n%s", ErrOldSettings.Error(), strings.Join(duplicates, "\n"))
+	}
+	return &meta, ErrOldSettings
+}
+
+// SaveMetadata writes the settings back out in the same layout LoadMetadata reads,
+// the new file replaces the old one in one go so nothing reading it sees it half written
+func SaveMetadata(filename string, meta *Metadata) error {
+	data, err := json.MarshalIndent(meta, "", "	")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.Wrapf(err, "failed to save settings: %s", filename)
+	}
+	return nil
 }
 
 func (s *Script) Get(index int) ScriptElement {
 	return s.Elements[index]
 }
 
+// Nearest finds where an element from an older version of the script ended up,
+// the closest identical element is used first, then the closest with the same marker,
+// otherwise the same index is kept as long as it's still in the script
+func (s *Script) Nearest(element ScriptElement, index int) int {
+	if index < 0 || len(s.Elements) == 0 {
+		return -1
+	}
+
+	sameMarker := func(v ScriptElement) bool {
+		return v.Name == element.Name && v.Mood == element.Mood && v.Action == element.Action
+	}
+	identical := func(v ScriptElement) bool {
+		return sameMarker(v) && v.Line == element.Line
+	}
+
+	for _, match := range []func(ScriptElement) bool{identical, sameMarker} {
+		best := -1
+		for i, v := range s.Elements {
+			if match(v) && (best < 0 || abs(i-index) < abs(best-index)) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+
+	if index >= len(s.Elements) {
+		return len(s.Elements) - 1
+	}
+	return index
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
 func (s *ScriptElement) ToString() string {
 	return fmt.Sprintf("[%v - %v - %v]: %v", s.Name, s.Mood, s.Action, s.Line)
 }
@@ -0,0 +1,39 @@
+package script
+
+// Skip is what skip mode knows about the line on screen, it moves the script on once per line
+// and stops at lines that haven't been read before
+type Skip struct {
+	// the script is going to move on by itself once a delay, fade or transition ends
+	queued bool
+	// the line on screen is being read for the first time
+	unread bool
+}
+
+// Queue records that the script is going to move on by itself, skip mode leaves it to do that
+func (s *Skip) Queue() {
+	s.queued = true
+}
+
+// Advanced is called once the script has moved on by itself
+func (s *Skip) Advanced() {
+	s.queued = false
+}
+
+// Shown records whether the line just put on screen had been read before
+func (s *Skip) Shown(read bool) {
+	s.unread = !read
+}
+
+// Start is called when skipping is turned on, the line on screen has been seen by then
+func (s *Skip) Start() {
+	s.unread = false
+}
+
+// Next is what skip mode does this frame, advance is true when it should move the script on
+// and stop is true when skipping should end on the line on screen
+func (s *Skip) Next(skipUnread bool) (advance, stop bool) {
+	if s.unread && !skipUnread {
+		return false, true
+	}
+	return !s.queued, false
+}
@@ -0,0 +1,107 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BlunterMonk/our_archive/internal/loop"
+)
+
+func TestSkipNext(t *testing.T) {
+	tests := []struct {
+		name       string
+		queued     bool
+		read       bool
+		skipUnread bool
+		advance    bool
+		stop       bool
+	}{
+		{"read line", false, true, false, true, false},
+		{"unread line", false, false, false, false, true},
+		{"unread line with skip unread", false, false, true, true, false},
+		{"queued advance", true, true, false, false, false},
+		{"queued advance on an unread line", true, false, false, false, true},
+	}
+	for _, tt := range tests {
+		var s Skip
+		s.Shown(tt.read)
+		if tt.queued {
+			s.Queue()
+		}
+		advance, stop := s.Next(tt.skipUnread)
+		if advance != tt.advance || stop != tt.stop {
+			t.Errorf("%s: got advance %v, stop %v, want %v, %v", tt.name, advance, stop, tt.advance, tt.stop)
+		}
+	}
+}
+
+// a delay moves the script on from a clock callback while skip mode runs every frame,
+// between them the script should only move on once so the unread line after the delay is stopped on
+func TestSkipStopsAfterDelay(t *testing.T) {
+	s, err := ParseScript("skip", strings.NewReader(strings.Join([]string{
+		"[_ - _ - _]",
+		"read before",
+		"[delay - _ - 1]",
+		"[_ - _ - _]",
+		"never read",
+		"[_ - _ - _]",
+		"also never read",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := map[int]bool{0: true}
+	shown := make([]int, 0)
+	status := make([]int, 0)
+	index := 0
+	var skip Skip
+
+	next := func() {
+		index++
+		v := s.Get(index)
+		if v.Name == "delay" {
+			// skip mode doesn't wait for delays, the script moves on as soon as the clock next runs
+			skip.Queue()
+			loop.After(0, func() {
+				status = append(status, 2)
+			})
+			return
+		}
+		shown = append(shown, index)
+		skip.Shown(read[index])
+		read[index] = true
+	}
+
+	// the same order as the main loop, clock callbacks, then skip mode, then status updates
+	stopped := false
+	for frame := 0; frame < 10 && !stopped; frame++ {
+		loop.Scene.Update(loop.DefaultStep)
+
+		advance, stop := skip.Next(false)
+		if stop {
+			stopped = true
+		} else if advance {
+			next()
+		}
+
+		for range status {
+			skip.Advanced()
+			next()
+		}
+		status = status[:0]
+	}
+
+	if !stopped {
+		t.Fatalf("skipping never stopped, got to line %d", index)
+	}
+	if index != 2 {
+		t.Errorf("stopped at element %d, want the unread line at 2", index)
+	}
+	if len(shown) != 1 || shown[0] != 2 {
+		t.Errorf("lines shown while skipping: %v, want only [2]", shown)
+	}
+	if read[3] {
+		t.Error("the line after the unread one was marked read")
+	}
+}
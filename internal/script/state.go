@@ -0,0 +1,205 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// comparison operators for [if] conditions, longer operators first so ">=" isn't read as ">"
+var conditionOperators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// State holds the variables and flags set by [set - <name> - <value>] while a script plays
+type State struct {
+	Variables map[string]string `json:"variables"`
+}
+
+func NewState() *State {
+	return &State{
+		Variables: make(map[string]string),
+	}
+}
+
+// Get returns the value of a variable, unset variables are empty
+func (s *State) Get(name string) string {
+	return s.Variables[name]
+}
+
+// Apply runs the value of a [set] marker against a variable
+//
+//	+1 / -1  add or subtract from the current number
+//	=-1      set the value exactly, used for negative numbers
+//	_        set a flag to true
+//	anything else is stored as is
+func (s *State) Apply(name, value string) error {
+	v, err := applyValue(s.Get(name), value)
+	if err != nil {
+		return err
+	}
+	s.Variables[name] = v
+	return nil
+}
+
+// Evaluate checks a variable against the condition of an [if] marker
+//
+//	>=3 / <=3 / >3 / <3  compare numbers
+//	==x / =x / x         equal to
+//	!=x                  not equal to
+//	_                    the flag is set and isn't false or 0
+func (s *State) Evaluate(name, condition string) (bool, error) {
+	op, operand, err := parseCondition(condition)
+	if err != nil {
+		return false, err
+	}
+	return compare(s.Get(name), op, operand)
+}
+
+func applyValue(current, value string) (string, error) {
+	switch {
+	case value == "_":
+		return "true", nil
+	case strings.HasPrefix(value, "="):
+		return value[1:], nil
+	case strings.HasPrefix(value, "+"), strings.HasPrefix(value, "-"):
+		diff, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("\"%s\" is not a number to add", value)
+		}
+		n, err := toNumber(current)
+		if err != nil {
+			return "", fmt.Errorf("can't add to \"%s\", it isn't a number", current)
+		}
+		return formatNumber(n + diff), nil
+	}
+	return value, nil
+}
+
+func parseCondition(condition string) (string, string, error) {
+	condition = strings.TrimSpace(condition)
+	if condition == "_" {
+		return "_", "", nil
+	}
+	for _, op := range conditionOperators {
+		if strings.HasPrefix(condition, op) {
+			operand := strings.TrimSpace(condition[len(op):])
+			if operand == "" {
+				return "", "", fmt.Errorf("condition \"%s\" has nothing to compare to", condition)
+			}
+			if op == "=" {
+				op = "=="
+			}
+			if op != "==" && op != "!=" {
+				if _, err := strconv.ParseFloat(operand, 64); err != nil {
+					return "", "", fmt.Errorf("condition \"%s\" needs a number to compare to", condition)
+				}
+			}
+			return op, operand, nil
+		}
+	}
+	return "==", condition, nil
+}
+
+func compare(value, op, operand string) (bool, error) {
+	switch op {
+	case "_":
+		return value != "" && value != "false" && value != "0", nil
+	case "==", "!=":
+		equal := value == operand
+		a, errA := strconv.ParseFloat(value, 64)
+		b, errB := strconv.ParseFloat(operand, 64)
+		if errA == nil && errB == nil {
+			equal = a == b
+		}
+		return equal == (op == "=="), nil
+	}
+
+	a, err := toNumber(value)
+	if err != nil {
+		return false, fmt.Errorf("can't compare \"%s\", it isn't a number", value)
+	}
+	b, err := strconv.ParseFloat(operand, 64)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case ">=":
+		return a >= b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	case "<":
+		return a < b, nil
+	}
+	return false, fmt.Errorf("unknown comparison \"%s\"", op)
+}
+
+// unset variables count as 0 so counters don't need to be set before they're used
+func toNumber(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// BlockEnd returns where execution continues when the block started at index is skipped,
+// for an [if] that's its [else] or [endif], for an [else] it's the [endif]
+func (s *Script) BlockEnd(index int) (int, bool) {
+	end, ok := s.Blocks[index]
+	return end, ok
+}
+
+// indexBlocks matches every [if] with its [else] and [endif]
+func (s *Script) indexBlocks() {
+	s.Blocks = make(map[int]int)
+
+	// indices of the [if] or [else] currently open
+	open := make([]int, 0)
+	for i, v := range s.Elements {
+		switch v.Name {
+		case "if":
+			open = append(open, i)
+		case "else":
+			if len(open) == 0 || s.Elements[open[len(open)-1]].Name != "if" {
+				s.errorf(v.LineNumber, 0, "[else] without a matching [if]")
+				continue
+			}
+			s.Blocks[open[len(open)-1]] = i
+			open[len(open)-1] = i
+		case "endif":
+			if len(open) == 0 {
+				s.errorf(v.LineNumber, 0, "[endif] without a matching [if]")
+				continue
+			}
+			s.Blocks[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+		}
+	}
+
+	for _, v := range open {
+		s.errorf(s.Elements[v].LineNumber, 0, "unterminated [%s] block, missing [endif - _ - _]", s.Elements[v].Name)
+	}
+}
+
+// checkState reports [set] and [if] markers that can't be evaluated
+func (s *Script) checkState(v ScriptElement) {
+	if v.Mood == "_" {
+		s.errorf(v.LineNumber, 0, "[%s] marker is missing a variable name, expected [%s - <name> - <value>]", v.Name, v.Name)
+		return
+	}
+
+	var err error
+	switch v.Name {
+	case "set":
+		_, err = applyValue("", v.Action)
+	case "if":
+		_, _, err = parseCondition(v.Action)
+	}
+	if err != nil {
+		s.errorf(v.LineNumber, 0, "%s", err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package script
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStateApply(t *testing.T) {
+	tests := []struct {
+		current string
+		value   string
+		want    string
+		err     bool
+	}{
+		{"", "_", "true", false},
+		{"", "+1", "1", false},
+		{"2", "+1.5", "3.5", false},
+		{"2", "-3", "-1", false},
+		{"5", "=-1", "-1", false},
+		{"x", "seia", "seia", false},
+		{"seia", "+1", "", true},
+		{"1", "+one", "", true},
+	}
+	for _, tt := range tests {
+		s := NewState()
+		if tt.current != "" {
+			s.Variables["v"] = tt.current
+		}
+		err := s.Apply("v", tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("Apply(%q) on %q: error %v, want error %v", tt.value, tt.current, err, tt.err)
+			continue
+		}
+		if !tt.err && s.Get("v") != tt.want {
+			t.Errorf("Apply(%q) on %q = %q, want %q", tt.value, tt.current, s.Get("v"), tt.want)
+		}
+	}
+}
+
+func TestStateEvaluate(t *testing.T) {
+	tests := []struct {
+		value     string
+		condition string
+		want      bool
+		err       bool
+	}{
+		{"", "_", false, false},
+		{"true", "_", true, false},
+		{"0", "_", false, false},
+		{"3", ">=3", true, false},
+		{"2", ">=3", false, false},
+		{"", "<1", true, false},
+		{"3", ">2", true, false},
+		{"3", "<=2", false, false},
+		{"3.0", "==3", true, false},
+		{"3", "=3", true, false},
+		{"seia", "seia", true, false},
+		{"seia", "!=mika", true, false},
+		{"seia", ">3", false, true},
+		{"3", ">three", false, true},
+		{"3", "==", false, true},
+	}
+	for _, tt := range tests {
+		s := NewState()
+		s.Variables["v"] = tt.value
+		got, err := s.Evaluate("v", tt.condition)
+		if (err != nil) != tt.err {
+			t.Errorf("Evaluate(%q) on %q: error %v, want error %v", tt.condition, tt.value, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) on %q = %v, want %v", tt.condition, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	s, err := ParseScript("blocks", strings.NewReader(strings.Join([]string{
+		"[if - a - _]",    // 0
+		"[if - b - _]",    // 1
+		"[endif - _ - _]", // 2
+		"[else - _ - _]",  // 3
+		"[endif - _ - _]", // 4
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start, want := range map[int]int{0: 3, 1: 2, 3: 4} {
+		if end, ok := s.BlockEnd(start); !ok || end != want {
+			t.Errorf("BlockEnd(%d) = %d, %v, want %d", start, end, ok, want)
+		}
+	}
+}
+
+func TestBlocksUnmatched(t *testing.T) {
+	tests := map[string][]string{
+		"missing endif":    {"[if - a - _]"},
+		"else without if":  {"[else - _ - _]"},
+		"endif without if": {"[endif - _ - _]"},
+	}
+	for name, lines := range tests {
+		s, _ := ParseScript(name, strings.NewReader(strings.Join(lines, "\n")))
+		if !s.Diagnostics.HasErrors() {
+			t.Errorf("%s: no error reported", name)
+		}
+	}
+}
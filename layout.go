@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+// layoutSlots is where actors stand across the screen for however many are on stage
+var layoutSlots = [][]float32{
+	{0},
+	{-0.5, 0.5},
+	{-0.6, 0, 0.6},
+	{-0.69, -0.23, 0.23, 0.69},
+	{-0.76, -0.38, 0, 0.38, 0.76},
+}
+
+var (
+	// set by the script, without it actors stay wherever their animations put them
+	layoutAuto bool
+	// actors on stage from left to right, in the order they came on
+	stageOrder = make([]string, 0)
+	// actors on their way off stage don't take up a slot
+	stageLeaving = make(map[string]bool)
+	// the slot each actor is heading to, so laying out again doesn't restart moves that are already right
+	slotTargets = make(map[string]hud.Vec3)
+	// how the speaker stands out, from settings.json
+	speakerSettings *script.SpeakerMetadata
+)
+
+// putsActorOnStage is false for actions that do something to an actor without showing them
+func putsActorOnStage(mood string) bool {
+	switch mood {
+	case "_", "animation", "stop", "wait", "layer":
+		return false
+	}
+	return true
+}
+
+// setLayout turns the layout on with "auto" and back off with "manual"
+func setLayout(mode string) {
+	switch mode {
+	case "auto":
+		layoutAuto = true
+		updateLayout(nil)
+	case "manual":
+		layoutAuto = false
+	default:
+		log.Printf("unknown layout \"%s\", use auto or manual\n", mode)
+	}
+}
+
+// syncStageOrder drops actors that have left the stage and adds new ones at the right
+func syncStageOrder() {
+	order := make([]string, 0, len(charSprite))
+	for _, name := range stageOrder {
+		if _, ok := charSprite[name]; ok {
+			order = append(order, name)
+		}
+	}
+
+	added := make([]string, 0)
+	for name := range charSprite {
+		found := false
+		for _, v := range order {
+			if v == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			added = append(added, name)
+		}
+	}
+	// more than one can only arrive at once from a save, keep it the same every time
+	sort.Strings(added)
+	stageOrder = append(order, added...)
+
+	for name := range slotTargets {
+		if _, ok := charSprite[name]; !ok {
+			delete(slotTargets, name)
+		}
+	}
+}
+
+// slotPosition is where the actor stands in slot i of count, using their own height and scale,
+// center_x is where they stand when placed by hand so the slot takes its place
+func slotPosition(actor *Actor, i, count int) hud.Vec3 {
+	var x float32
+	if count <= len(layoutSlots) {
+		x = layoutSlots[count-1][i]
+	} else {
+		// past the last layout everyone is spread evenly
+		x = -0.8 + 1.6*float32(i)/float32(count-1)
+	}
+	center := actor.GetCenter()
+	if center.Z() == 0 {
+		// actors missing from settings.json keep their own height and size
+		resting := actor.RestingPose()
+		return hud.Vec3{x, resting.Position.Y(), resting.Scale}
+	}
+	return hud.Vec3{x, center.Y(), center.Z()}
+}
+
+// updateLayout moves everyone on stage into the slots for however many are there,
+// arriving is put straight into its slot while everyone else moves over
+func updateLayout(arriving *Actor) {
+	syncStageOrder()
+	if !layoutAuto {
+		return
+	}
+
+	staying := make([]string, 0, len(stageOrder))
+	for _, name := range stageOrder {
+		if !stageLeaving[name] {
+			staying = append(staying, name)
+		}
+	}
+
+	for i, name := range staying {
+		actor := charSprite[name]
+		target := slotPosition(actor, i, len(staying))
+		if actor == arriving {
+			placeInSlot(actor, target)
+			continue
+		}
+		if current, ok := slotTargets[name]; ok && current == target {
+			continue
+		}
+		moveToSlot(actor, target)
+	}
+}
+
+// placeInSlot puts the actor in their slot straight away
+func placeInSlot(actor *Actor, target hud.Vec3) {
+	slotTargets[actor.name] = target
+	actor.StopAnimation(layoutTrack)
+
+	pose := actor.GetPose()
+	resting := actor.RestingPose()
+	pose.Position = pose.Position.Add(hud.Vec3{target.X(), target.Y(), 0}.Sub(resting.Position))
+	pose.Scale = target.Z()
+	actor.SetPose(pose)
+}
+
+// moveToSlot tweens the actor into their slot, moving by the difference so loops playing on them carry on
+func moveToSlot(actor *Actor, target hud.Vec3) {
+	slotTargets[actor.name] = target
+
+	resting := actor.RestingPose()
+	frame := script.FrameMetadata{
+		AddX:     setting(target.X() - resting.Position.X()),
+		AddY:     setting(target.Y() - resting.Position.Y()),
+		Scale:    setting(target.Z()),
+		Duration: setting(0.5),
+		Easing:   "ease_in_out_cubic",
+	}
+	actor.PlayAnimation(layoutTrack, script.AnimationMetadata{Frames: []script.FrameMetadata{frame}, Track: layoutTrack}, nil)
+}
+
+// resetLayout forgets who was on stage, for when the stage is cleared
+func resetLayout() {
+	stageOrder = make([]string, 0)
+	stageLeaving = make(map[string]bool)
+	slotTargets = make(map[string]hud.Vec3)
+}
+
+// speakerOnTop is true when the speaker is drawn in front of everyone on the same layer as them
+func speakerOnTop() bool {
+	return speakerSettings == nil || speakerSettings.OnTop == nil || *speakerSettings.OnTop
+}
+
+// drawOrder lists the actors on stage from back to front: lower layers first,
+// then on the same layer the speaker goes last if they're on top and everyone else in the order they came on
+func drawOrder() []string {
+	syncStageOrder()
+	order := append([]string{}, stageOrder...)
+	onTop := speakerOnTop()
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := charSprite[order[i]], charSprite[order[j]]
+		if a.Layer != b.Layer {
+			return a.Layer < b.Layer
+		}
+		if onTop && (order[i] == CurrentSpeaker) != (order[j] == CurrentSpeaker) {
+			return order[j] == CurrentSpeaker
+		}
+		return false
+	})
+	return order
+}
+
+// setActorLayer moves an actor to a layer, front and back go past everyone else on stage
+// and reset goes back to the layer from settings.json
+func setActorLayer(actor *Actor, layer string) {
+	switch layer {
+	case "front", "back":
+		first := true
+		for name, other := range charSprite {
+			if name == actor.name {
+				continue
+			}
+			if layer == "front" && (first || other.Layer >= actor.Layer) {
+				actor.Layer = other.Layer + 1
+			}
+			if layer == "back" && (first || other.Layer <= actor.Layer) {
+				actor.Layer = other.Layer - 1
+			}
+			first = false
+		}
+	case "reset":
+		actor.Layer = actor.defaultLayer
+	default:
+		n, err := strconv.Atoi(layer)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		actor.Layer = n
+	}
+}
+
+// verifyLayer checks a layer from the script
+func verifyLayer(layer string) error {
+	switch layer {
+	case "front", "back", "reset":
+		return nil
+	}
+	if _, err := strconv.Atoi(layer); err != nil {
+		return fmt.Errorf("unknown layer \"%s\", use front, back, reset or a whole number", layer)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+// runLint checks scripts without opening a window and returns the exit code for the process
+// every script is checked when no names are given
+func runLint(names []string) int {
+	if len(names) == 0 {
+		if flagScriptName != nil && *flagScriptName != "" {
+			names = append(names, *flagScriptName)
+		} else {
+			for _, v := range loadScriptFilenames() {
+				names = append(names, strings.TrimSuffix(v, filepath.Ext(v)))
+			}
+		}
+	}
+
+	exitCode := 0
+	metadata, err := script.LoadMetadata(settingsFile)
+	if err == script.ErrOldSettings {
+		fmt.Printf("%s: warning: %s\n", settingsFile, err.Error())
+	} else if err != nil {
+		fmt.Printf("%s: error: %s\n", settingsFile, err.Error())
+		exitCode = 1
+		if metadata == nil {
+			return exitCode
+		}
+	}
+
+	for _, name := range names {
+		problems := lintScript(scriptPath(name), metadata)
+		for _, v := range problems {
+			fmt.Println(v)
+		}
+
+		errCount := len(problems.Errors())
+		fmt.Printf("%s: %d errors, %d warnings\n", name, errCount, len(problems.Warnings()))
+		if errCount > 0 {
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+// lintScript parses a script and checks that every resource it references exists on disk
+func lintScript(filename string, metadata *script.Metadata) script.Diagnostics {
+	s, err := script.LoadScript(filename)
+	if s == nil {
+		return script.Diagnostics{{File: filename, Message: err.Error(), Severity: script.SeverityError}}
+	}
+
+	problems := append(make(script.Diagnostics, 0), s.Diagnostics...)
+	// problems found while parsing are already in the diagnostics, anything else means the script wasn't read to the end
+	if _, parsed := err.(script.Diagnostics); err != nil && !parsed {
+		problems = append(problems, script.Diagnostic{File: filename, Message: err.Error(), Severity: script.SeverityError})
+	}
+
+	// clones are cached globally while queueing, start fresh for every script
+	Clones = make(map[string]string)
+	loads, missing := queueScriptResources(s, metadata)
+	problems = append(problems, missing...)
+
+	for _, v := range loads {
+		if v.Line == 0 {
+			continue
+		}
+
+		files := []loadEvent{v}
+		if v.Category == "emote" {
+			// emotes play a sound effect with the same name
+			files = append(files, loadEvent{Key: v.Key, Category: "sfx", Line: v.Line})
+		}
+		for _, f := range files {
+			path := resourcePath(f)
+			if path != "" && !FileExists(path) {
+				problems = append(problems, script.Diagnostic{
+					File:     filename,
+					Line:     f.Line,
+					Message:  fmt.Sprintf("missing %s \"%s\", expected it at %s", f.Category, f.Key, path),
+					Severity: script.SeverityError,
+				})
+			}
+		}
+	}
+
+	for _, v := range s.Elements {
+		if v.Name != "clone" || v.Mood == "_" {
+			continue
+		}
+		path := fmt.Sprintf("./resources/actor/%s", v.Mood)
+		if !FileExists(path) {
+			problems = append(problems, script.Diagnostic{
+				File:     filename,
+				Line:     v.LineNumber,
+				Message:  fmt.Sprintf("clone target \"%s\" has no sprites, expected them in %s", v.Mood, path),
+				Severity: script.SeverityError,
+			})
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+
+	return problems
+}
@@ -6,11 +6,11 @@ import (
 	"image"
 	"image/png"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -76,7 +76,6 @@ var (
 	speakerScale     = 1.2
 	dialogueDone     bool
 	dialogueIndex    = -1
-	CurrentBG        string
 	CurrentSpeaker   string
 	CurrentFontSize  float32
 	CurrentBgmVolume = float64(-2)
@@ -87,15 +86,18 @@ var (
 	FPS              int
 
 	Script *script.Script
+	State  *script.State // variables set by the script while it plays
 	// Metadata        *script.Metadata
-	FRAME_DURATION  = 16 * time.Millisecond
+	FRAME_DURATION  = loop.DefaultStep
 	UniversalTicker = time.Tick(FRAME_DURATION)
 
 	// used to send events to main loop
 	EventQueue   = make([]eventFunc, 0)
 	EventChannel = make(chan loadEvent)
-	DebugChannel = make(chan string)
-	status       = make(chan uint32)
+	// buffered like status, messages sent from the main thread must never wait on the loop that reads them
+	DebugChannel = make(chan string, 16)
+	// buffered so updates can be sent from the main thread, where the scene clock runs callbacks
+	status = make(chan uint32, 64)
 
 	// static assets
 	dialogue, subjectName *hud.Text
@@ -116,20 +118,28 @@ var (
 	Sprites         map[string]*hud.Sprite
 
 	currentBGM           *sfx.Streamer
+	currentBGMName       string
 	shuttingDown         bool
 	useStrictCoreProfile = (runtime.GOOS == "darwin")
 	shaderProgram        *gfx.Program
 
-	ACTOR_LEFT           = hud.Vec3{-0.5, -0.65, 0.0}
-	ACTOR_RIGHT          = hud.Vec3{0.5, -0.65, 0.0}
 	AUTO                 = false
 	DEBUG                = false
 	DEBUG_TEXT           string
 	LOADING              = false
 	WAITING_CONFIRMATION = false
+	NextLabel            string // label chosen by a reply, the script continues from here on the next line
 
 	program        = kingpin.New("our_archive", "our_archive")
 	flagScriptName = program.Flag("script", "name of the script to run").Short('s').String()
+	flagSkipUnread = program.Flag("skip-unread", "let skip mode continue past lines that haven't been read before").Bool()
+	playCommand    = program.Command("play", "play a script in a window").Default()
+	lintCommand    = program.Command("lint", "check scripts for mistakes and missing resources without opening a window")
+	lintScripts    = lintCommand.Arg("scripts", "names of the scripts to check, defaults to --script or every script").Strings()
+	exportCommand  = program.Command("export", "render --script to numbered png frames and a wav file that ffmpeg can put together into a video")
+	exportOutput   = exportCommand.Flag("out", "folder the frames and audio are written to, defaults to ./export/<script>").String()
+	exportFPS      = exportCommand.Flag("fps", "frames per second of the video").Default("60").Int()
+	exportChoices  = exportCommand.Flag("choice", "reply to pick at each choice in order, 1 is the first reply, the first reply is picked once these run out").Ints()
 	// flagLogLevel = program.Flag("log", "log level").String()
 
 	// HUD Rects
@@ -150,6 +160,7 @@ type loadEvent struct {
 	Category string
 	Key      string
 	Object   string
+	Line     int // line in the script that needs this resource, 0 for system resources
 }
 type View struct {
 	speakerX     float32
@@ -161,11 +172,14 @@ type View struct {
 }
 type Reply struct {
 	*hud.Text
-	Position hud.Vec2
-	Active   bool
-	Sprite   *hud.Sprite
-	start    *hud.Animation
-	end      *hud.Animation
+	Position  hud.Vec2
+	Active    bool
+	Sprite    *hud.Sprite
+	Rect      image.Rectangle // clickable area in window coordinates
+	Target    string          // label the script continues from when this reply is picked
+	transform hud.Mat4
+	start     *hud.Animation
+	end       *hud.Animation
 }
 
 func (r *Reply) IsAnimating() bool {
@@ -184,22 +198,34 @@ var scriptName string
 // go run ./... -ldflags "-H windowsgui" "rabu"
 func main() {
 
-	_, err := program.Parse(os.Args[1:])
+	command, err := program.Parse(os.Args[1:])
 	if err != nil {
-		_, err := program.Parse(os.Args[3:])
+		command, err = program.Parse(os.Args[3:])
 		if err != nil {
 			panic(err)
 		}
 	}
 
+	if command == lintCommand.FullCommand() {
+		os.Exit(runLint(*lintScripts))
+	}
+
 	if flagScriptName != nil && *flagScriptName != "" {
 		scriptName = *flagScriptName
-	} else {
-		scriptName = "test"
 	}
 
+	if command == exportCommand.FullCommand() {
+		if scriptName == "" {
+			fmt.Println("pick the script to export with --script")
+			os.Exit(1)
+		}
+		os.Exit(runExport(CURRENT_VIEW, scriptName))
+	}
+
+	// without a script the game opens on the script picker
+
 	loadGame(CURRENT_VIEW, scriptName)
-	runGame(CURRENT_VIEW, scriptName)
+	runGame(CURRENT_VIEW)
 }
 
 func loadScriptFilenames() []string {
@@ -237,27 +263,35 @@ func loadResource(load loadEvent) error {
 		}
 	case "bg":
 		if _, ok := Backgrounds[key]; !ok {
-			Backgrounds[key], err = hud.NewSpriteFromFile(fmt.Sprintf("./resources/%s/%s.jpeg", category, key))
+			Backgrounds[key], err = hud.NewSpriteFromFile(resourcePath(load))
 		}
 	case "bgm":
 		if _, ok := Sounds[key]; !ok {
-			Sounds[key], err = sfx.NewStreamer(fmt.Sprintf("./resources/%s/%s.mp3", category, key))
+			Sounds[key], err = sfx.NewStreamer(resourcePath(load))
 		}
 	case "sfx":
 		if _, ok := Sounds[key]; !ok {
-			s, err := sfx.NewStreamer(fmt.Sprintf("./resources/%s/%s.mp3", category, key))
+			s, err := sfx.NewStreamer(resourcePath(load))
 			if err != nil {
 				return err
 			}
 			Sounds[key] = s
 		}
+	case "voice":
+		if _, ok := Voices[key]; !ok {
+			s, err := sfx.NewStreamer(resourcePath(load))
+			if err != nil {
+				return err
+			}
+			Voices[key] = s
+		}
 	case "emote": // load the emote if it isn't already
 		if _, ok := Emotes[key]; !ok {
-			Emotes[key] = hud.NewAnimatedSpriteFromFile(fmt.Sprintf("./resources/%s/%s.gif", category, key))
+			Emotes[key] = hud.NewAnimatedSpriteFromFile(resourcePath(load))
 
 			// load sfx for emote
 			if _, ok := Sounds[key]; !ok {
-				Sounds[key], err = sfx.NewStreamer(fmt.Sprintf("./resources/sfx/%s.mp3", key))
+				Sounds[key], err = sfx.NewStreamer(resourcePath(loadEvent{Key: key, Category: "sfx"}))
 			}
 		}
 	case "name":
@@ -273,12 +307,6 @@ func loadResource(load loadEvent) error {
 			Factions[name].SetScale(0.8)
 		}
 	case "actor":
-		originalName := objectName
-		if k, ok := Clones[objectName]; ok {
-			// fmt.Println("replacing cloned name", objectName, k)
-			originalName = k
-		}
-
 		if _, ok := Actors[objectName]; !ok {
 			// need to create the actor in the same thread as the sprites
 			// for whatever reason, when the sprite and actor are created separately
@@ -287,9 +315,9 @@ func loadResource(load loadEvent) error {
 		}
 
 		// fmt.Printf("loading %s texture for %s\n", key, objectName)
-		err = Actors[objectName].LoadTexture(key, fmt.Sprintf("./resources/%s/%s/%s-%s.png", category, originalName, originalName, key))
+		err = Actors[objectName].LoadTexture(key, resourcePath(load))
 	case "sprite":
-		Sprites[key], err = hud.NewSpriteFromFile(fmt.Sprintf("./resources/%s/%s.png", objectName, key))
+		Sprites[key], err = hud.NewSpriteFromFile(resourcePath(load))
 		if key == spriteEmoteBalloon {
 			Sprites[key].SetScale(0.085)
 		}
@@ -302,11 +330,48 @@ func loadResource(load loadEvent) error {
 		err = fade.LoadTexture("white", "./resources/bg/white_screen.jpeg")
 		fade.SetPositionf(0, 0, 0)
 		fade.SetAlpha(0)
+
+		backlogShade = hud.NewSprite()
+		backlogShade.LoadTexture("black", "./resources/bg/black_screen.jpeg")
+		backlogShade.SetAlpha(0.8)
 	}
 
 	return err
 }
 
+// resourcePath is the file a load event reads from, empty for resources that aren't files
+func resourcePath(load loadEvent) string {
+	switch load.Category {
+	case "bg":
+		return findResource(fmt.Sprintf("./resources/%s/%s", load.Category, load.Key), backgroundExtensions)
+	case "bgm", "sfx", "voice":
+		return fmt.Sprintf("./resources/%s/%s.mp3", load.Category, load.Key)
+	case "emote":
+		return fmt.Sprintf("./resources/%s/%s.gif", load.Category, load.Key)
+	case "actor":
+		originalName := load.Object
+		if k, ok := Clones[load.Object]; ok {
+			// fmt.Println("replacing cloned name", objectName, k)
+			originalName = k
+		}
+		return fmt.Sprintf("./resources/%s/%s/%s-%s.png", load.Category, originalName, originalName, load.Key)
+	case "sprite":
+		return fmt.Sprintf("./resources/%s/%s.png", load.Object, load.Key)
+	}
+	return ""
+}
+
+// findResource is the first file at path with one of the extensions,
+// when there isn't one the first extension is used so the error says where it was expected
+func findResource(path string, extensions []string) string {
+	for _, ext := range extensions {
+		if FileExists(path + ext) {
+			return path + ext
+		}
+	}
+	return path + extensions[0]
+}
+
 func queueResources(view View, scriptName string) ([]loadEvent, *script.Metadata, []error) {
 	var err error
 
@@ -332,42 +397,133 @@ func queueResources(view View, scriptName string) ([]loadEvent, *script.Metadata
 
 	fmt.Println("system resources loaded")
 
-	Script = script.NewScriptFromFile(fmt.Sprintf("./resources/scripts/%s.txt", scriptName))
-	metadata, err := script.LoadMetadata("./resources/settings.json")
+	if scriptName == "" {
+		// only the system resources are needed for the script picker
+		Script = &script.Script{}
+		metadata, err := script.LoadMetadata(settingsFile)
+		if err != nil {
+			missing = append(missing, err)
+		}
+		return loads, metadata, missing
+	}
+
+	Script, err = script.NewScriptFromFile(scriptPath(scriptName))
 	if err != nil {
 		missing = append(missing, err)
+	} else {
+		for _, v := range Script.Diagnostics {
+			log.Println(v)
+		}
+	}
+	if Script == nil {
+		// the script couldn't be read at all, keep going with nothing to play so the error shows up on screen
+		Script = &script.Script{}
+	}
+	metadata, err := script.LoadMetadata(settingsFile)
+	if err != nil {
+		missing = append(missing, err)
+	}
+
+	scriptLoads, problems := queueScriptResources(Script, metadata)
+	loads = append(loads, scriptLoads...)
+	if len(problems) > 0 {
+		missing = append(missing, problems)
+	}
+
+	filtered := make([]error, 0)
+	for _, v := range missing {
+		if v != nil {
+			filtered = append(filtered, v)
+		}
 	}
 
+	return loads, metadata, filtered
+}
+
+// queueScriptResources lists everything the script needs loaded,
+// any animation the script uses that isn't in the settings is reported against the line that uses it
+func queueScriptResources(s *script.Script, metadata *script.Metadata) ([]loadEvent, script.Diagnostics) {
+	loads := make([]loadEvent, 0)
+	problems := make(script.Diagnostics, 0)
+
 	// search for clones first so we can populate the cache
-	for _, v := range Script.Elements {
+	for _, v := range s.Elements {
 		if v.Name == "clone" {
 			Clones[v.Action] = v.Mood
 		}
 	}
 
+	// characters can be panned to before they're loaded
+	names := make(map[string]bool)
+	for _, v := range s.Elements {
+		names[v.Name] = true
+	}
+
 	loads = append(loads, loadEvent{Key: "all", Category: "name"})
-	for _, v := range Script.Elements {
+	for _, v := range s.Elements {
+		if v.Voice != "" {
+			loads = append(loads, loadEvent{Key: v.Voice, Category: "voice", Line: v.LineNumber})
+		}
+		if !script.IsSceneSubject(v.Name) {
+			v.Mood, v.Action = elementAction(v)
+		}
 		switch v.Name {
 		case "all":
-			if v.Action != "emote" && v.Action != "_" {
-				missing = append(missing, verifyAnimation(v.Action, metadata))
+			if v.Mood == "emote" {
+				loads = append(loads, loadEvent{Key: v.Action, Category: "emote", Line: v.LineNumber})
+			} else if v.Action != "_" {
+				problems = appendAnimationProblem(problems, s.Filename, v, metadata)
 			}
 			continue
-		case "clone", "defect", "delay", "none", "clear", "_", "font", "fade":
+		case "clone", "defect", "delay", "none", "clear", "_", "font", "fade", "label", "jump", "set", "if", "else", "endif":
 			// special action tags don't need to be loaded
 			continue
+		case "layout":
+			if v.Mood != "auto" && v.Mood != "manual" {
+				problems = append(problems, script.Diagnostic{
+					File:     s.Filename,
+					Line:     v.LineNumber,
+					Message:  fmt.Sprintf("unknown layout \"%s\", use auto or manual", v.Mood),
+					Severity: script.SeverityError,
+				})
+			}
+			continue
+		case "camera":
+			if err := verifyCamera(v, names); err != nil {
+				problems = append(problems, script.Diagnostic{
+					File:     s.Filename,
+					Line:     v.LineNumber,
+					Message:  err.Error(),
+					Severity: script.SeverityError,
+				})
+			}
+			continue
 		case "bgm":
 			switch v.Mood {
 			case "pause", "resume", "fade", "_":
 				continue
 			case "play":
-				loads = append(loads, loadEvent{Key: v.Action, Category: "bgm"})
+				loads = append(loads, loadEvent{Key: v.Action, Category: "bgm", Line: v.LineNumber})
 			default:
-				loads = append(loads, loadEvent{Key: v.Mood, Category: "bgm"})
+				loads = append(loads, loadEvent{Key: v.Mood, Category: "bgm", Line: v.LineNumber})
 			}
 			continue
-		case "bg", "sfx", "emote": //, "name", "faction", "sprite":
-			loads = append(loads, loadEvent{Key: v.Mood, Category: v.Name})
+		case layerBackground, layerMidground, layerForeground:
+			if err := verifyTransition(v.Action); err != nil {
+				problems = append(problems, script.Diagnostic{
+					File:     s.Filename,
+					Line:     v.LineNumber,
+					Message:  err.Error(),
+					Severity: script.SeverityError,
+				})
+			}
+			// every layer loads from the bg folder, "_" empties the layer
+			if v.Mood != "_" {
+				loads = append(loads, loadEvent{Key: v.Mood, Category: "bg", Line: v.LineNumber})
+			}
+			continue
+		case "sfx", "emote": //, "name", "faction", "sprite":
+			loads = append(loads, loadEvent{Key: v.Mood, Category: v.Name, Line: v.LineNumber})
 			continue
 		}
 
@@ -385,28 +541,54 @@ func queueResources(view View, scriptName string) ([]loadEvent, *script.Metadata
 		// check to see if the action is an emote
 		switch v.Mood {
 		case "fade", "full", "silhouette", "rename", "defect":
+		case "layer":
+			if err := verifyLayer(v.Action); err != nil {
+				problems = append(problems, script.Diagnostic{
+					File:     s.Filename,
+					Line:     v.LineNumber,
+					Message:  err.Error(),
+					Severity: script.SeverityError,
+				})
+			}
+		case "stop", "wait":
+			if err := verifyTrack(v.Action, metadata); err != nil {
+				problems = append(problems, script.Diagnostic{
+					File:     s.Filename,
+					Line:     v.LineNumber,
+					Message:  err.Error(),
+					Severity: script.SeverityError,
+				})
+			}
 		case "emote": // load the emote if it isn't already
-			loads = append(loads, loadEvent{Key: v.Action, Category: "emote"})
+			loads = append(loads, loadEvent{Key: v.Action, Category: "emote", Line: v.LineNumber})
 		case "animation", "_":
 			if v.Action != "_" {
-				missing = append(missing, verifyAnimation(v.Action, metadata))
+				problems = appendAnimationProblem(problems, s.Filename, v, metadata)
 			}
 		default: // if it's not an emote, then load the texture onto the actor as an expression
-			loads = append(loads, loadEvent{Object: v.Name, Key: v.Mood, Category: "actor"})
+			loads = append(loads, loadEvent{Object: v.Name, Key: v.Mood, Category: "actor", Line: v.LineNumber})
 			if v.Action != "_" {
-				missing = append(missing, verifyAnimation(v.Action, metadata))
+				problems = appendAnimationProblem(problems, s.Filename, v, metadata)
 			}
 		}
 	}
 
-	filtered := make([]error, 0)
-	for _, v := range missing {
-		if v != nil {
-			filtered = append(filtered, v)
-		}
-	}
+	return loads, problems
+}
 
-	return loads, metadata, filtered
+func appendAnimationProblem(problems script.Diagnostics, filename string, element script.ScriptElement, metadata *script.Metadata) script.Diagnostics {
+	if _, ok := parseStageAction(element.Action); ok {
+		return problems
+	}
+	if err := verifyAnimation(element.Action, metadata); err != nil {
+		problems = append(problems, script.Diagnostic{
+			File:     filename,
+			Line:     element.LineNumber,
+			Message:  err.Error(),
+			Severity: script.SeverityError,
+		})
+	}
+	return problems
 }
 
 func applyMetadata(metadata *script.Metadata) {
@@ -420,6 +602,9 @@ func applyMetadata(metadata *script.Metadata) {
 			if actor.FactionName != nil && *actor.FactionName != "" {
 				a.FactionName = *actor.FactionName
 			}
+			a.headOffset = hud.Vec3{actor.EmoteOffsetHead.X, actor.EmoteOffsetHead.Y, 0}
+			a.defaultLayer = actor.Layer
+			a.Layer = actor.Layer
 
 			// add emote data to actor
 			for emoteName, emote := range metadata.Emotes {
@@ -438,14 +623,25 @@ func applyMetadata(metadata *script.Metadata) {
 		}
 	}
 	ActorAnimations = metadata.Animations
+	cameraSettings = metadata.Camera
+	speakerSettings = metadata.Speaker
 }
 
 func verifyAnimation(name string, metadata *script.Metadata) error {
-	if _, ok := metadata.Animations[name]; ok {
-		return nil
+	anim, ok := metadata.Animations[name]
+	if !ok {
+		return fmt.Errorf("animation with name \"%s\" found in script, but not in settings.json", name)
 	}
 
-	return fmt.Errorf("animation with name \"%s\" found in script, but not in settings.json", name)
+	if _, ok := metadata.Animations[anim.Next]; anim.Next != "" && !ok {
+		return fmt.Errorf("animation \"%s\" is followed by \"%s\", but it isn't in settings.json", name, anim.Next)
+	}
+	for i, frame := range anim.Frames {
+		if _, ok := loop.GetEase(frame.Easing); !ok {
+			return fmt.Errorf("frame %d of animation \"%s\" uses an unknown easing \"%s\", try one of: %s", i+1, name, frame.Easing, strings.Join(loop.EaseNames(), ", "))
+		}
+	}
+	return nil
 }
 
 func releaseResources() {
@@ -458,6 +654,12 @@ func releaseResources() {
 			v.Release()
 		}
 	}
+	stopVoice()
+	for _, v := range Voices {
+		if v != nil {
+			v.Release()
+		}
+	}
 
 	charSprite = make(map[string]*Actor)
 	Actors = make(map[string]*Actor)
@@ -468,7 +670,11 @@ func releaseResources() {
 	Factions = make(map[string]*hud.Text)
 	Names = make(map[string]*hud.Text)
 	Sounds = make(map[string]*sfx.Streamer)
+	Voices = make(map[string]*sfx.Streamer)
 	Sprites = make(map[string]*hud.Sprite)
+	State = script.NewState()
+	resetBacklog()
+	resetRewind()
 	if Fonts == nil {
 		Fonts = make(map[string]*v41.Font)
 	}
@@ -485,6 +691,10 @@ func shutdown() {
 
 	releaseResources()
 	sfx.Close()
+
+	if err := saveReadHistory(); err != nil {
+		log.Printf("failed to save the read history: %v\n", err)
+	}
 }
 
 func loadGame(view View, scriptName string) {
@@ -506,8 +716,8 @@ func loadGame(view View, scriptName string) {
 			// fmt.Println("queuing event:", v)
 			EventChannel <- v
 		}
-		log.Println("applying metadata")
-		applyMetadata(metadata)
+		// metadata is applied by the main thread once the channel is closed, since it changes the actors being loaded
+		pendingMetadata = metadata
 		log.Println("finishing load")
 		close(EventChannel)
 	}()
@@ -515,12 +725,18 @@ func loadGame(view View, scriptName string) {
 
 var replyStart, replyEnd *hud.Animation
 
-func runGame(CurrentViewConfig View, scriptName string) {
+// metadata waiting to be applied when loading finishes
+var pendingMetadata *script.Metadata
+
+func runGame(CurrentViewConfig View) {
 	var xCode int
 
 	window := gfx.Init(CurrentViewConfig.WindowWidth, CurrentViewConfig.WindowHeight)
 	window.SetKeyCallback(keyCallback)
 	window.SetMouseButtonCallback(mouseButtonCallback)
+	window.SetScrollCallback(scrollCallback)
+	window.SetCharCallback(editorCharCallback)
+	window.SetCursorPosCallback(editorCursorCallback)
 	sfx.Init()
 	hud.Init()
 
@@ -528,6 +744,7 @@ func runGame(CurrentViewConfig View, scriptName string) {
 	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT)
 
 	ft := time.Tick(time.Second)
+	reloadTicker := time.Tick(reloadInterval)
 	killswitch := make(chan int)
 	shaderProgram = gfx.MustInitShader()
 
@@ -575,10 +792,10 @@ func runGame(CurrentViewConfig View, scriptName string) {
 	// get 2D projection matrix for the aspect ratio
 	var screenProjMatrix hud.Mat4 = hud.ProjMatrix(float32(CurrentViewConfig.WindowWidth), float32(CurrentViewConfig.WindowHeight))
 
-	var delay time.Timer
 	var debugText *hud.Text
 	var debugString string
 	var counter int
+	lastFrame := time.Now()
 	loadErrors := make([]error, 0)
 F:
 	for {
@@ -606,32 +823,26 @@ F:
 		// events must be handled on the main thread if they interact with OpenGL
 		// this is a limitation on the OpenGL system where it will panic if changes are made by different threads
 		case s := <-status:
-			log.Printf("received a status update: %v\n", s)
-			switch s {
-			case 0:
+			if !handleStatus(s) {
 				break F
-			case 1:
-				delay = *time.NewTimer(time.Second)
-			case 2:
-				nextDialogue(&status)
-			case 3:
-				WAITING_CONFIRMATION = false
-				nextDialogue(&status)
 			}
-		case <-delay.C:
-			if AUTO {
-				if dialogue != nil && dialogue.Done() {
-					// if s, ok := Sounds["next"]; ok {
-					// 	s.Play()
-					// }
-					nextDialogue(&status)
-				}
+
+		case <-reloadTicker:
+			// a reload replaces whatever was on screen, problems stay up until the next one fixes them
+			if reloaded, problems := checkForChanges(); reloaded {
+				debugString = problems
+				debugText = nil
 			}
 
 		case <-UniversalTicker:
 			// fmt.Println("ticker channel:", counter)
 			counter++
 
+			// move every animation forward by however much time passed, in fixed steps
+			now := time.Now()
+			loop.Scene.Update(now.Sub(lastFrame))
+			lastFrame = now
+
 			// enable shader
 			shaderProgram.Use()
 
@@ -668,6 +879,20 @@ F:
 						for i := range EventChannel {
 							fmt.Printf("flushing %v\n", i)
 						}
+						if pendingMetadata != nil {
+							log.Println("applying metadata")
+							applyMetadata(pendingMetadata)
+							pendingMetadata = nil
+						}
+						if pendingSave != nil {
+							restoreSave(pendingSave)
+							pendingSave = nil
+						}
+						watchFiles()
+						// nothing was picked yet, so the picker stays open until something is
+						if scriptName == "" {
+							openMenu()
+						}
 					}
 				}
 
@@ -677,10 +902,16 @@ F:
 				break
 			}
 
+			skipStep()
+
 			// draw image
 
+			beginCamera()
 			drawBackgrounds()
 			drawActors(screenProjMatrix)
+			drawForeground()
+			endCamera()
+			drawEditorActor(screenProjMatrix)
 			drawUI(CurrentViewConfig, screenProjMatrix)
 			drawText(CurrentViewConfig)
 			if debugText != nil {
@@ -693,6 +924,11 @@ F:
 			gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 
 			drawOverlays()
+			drawSkip(CurrentViewConfig)
+			drawBacklog(CurrentViewConfig)
+			drawPause(CurrentViewConfig)
+			drawMenu(CurrentViewConfig)
+			drawEditor(CurrentViewConfig)
 
 			// end of draw loop
 			window.SwapBuffers()
@@ -712,46 +948,80 @@ F:
 	os.Exit(xCode)
 }
 
-func drawBackgrounds() {
-	if bg, ok := Backgrounds[CurrentBG]; ok {
-		DrawSprite(bg, hud.NewMat4(), shaderProgram) // background
+// handleStatus runs a status update sent to the main loop, false means the game should close
+func handleStatus(s uint32) bool {
+	log.Printf("received a status update: %v\n", s)
+	switch s {
+	case 0:
+		return false
+	case 1:
+		startAutoTimer()
+	case 2:
+		skipState.Advanced()
+		nextDialogue(&status)
+	case 3:
+		WAITING_CONFIRMATION = false
+		nextDialogue(&status)
+	}
+	return true
+}
+
+// increased for every auto timer so only the newest one moves the script on
+var autoTimer int
+
+// startAutoTimer moves to the next line when auto mode is on, after a second
+// or once the line's voice has finished
+func startAutoTimer() {
+	autoTimer++
+	timer := autoTimer
+	next := func() {
+		if timer != autoTimer || !AUTO {
+			return
+		}
+		if dialogue != nil && dialogue.Done() {
+			// if s, ok := Sounds["next"]; ok {
+			// 	s.Play()
+			// }
+			nextDialogue(&status)
+		}
+	}
+	if voicePlaying() {
+		afterVoice(func() {
+			loop.After(voiceAutoPause, next)
+		})
+		return
 	}
+	loop.After(time.Second, next)
 }
+
 func drawActors(proj hud.Mat4) {
 	// if sprite, ok := charSprite["akira"]; ok {
 	// 	// DrawSprite(sprite.Sprite, hud.NewMat4(), shaderProgram)
 	// 	sprite.Draw(shaderProgram)
 	// }
 	// return
-	keys := make([]string, 0, len(charSprite))
-	for k := range charSprite {
-		// don't add the current speaker to the list so we can draw them last
-		if k != CurrentSpeaker {
-			keys = append(keys, k)
-		}
-	}
-	// sort all the actors other than the one speaking
-	sort.Strings(keys)
-	keys = append(keys, CurrentSpeaker)
-	for _, name := range keys {
+	for _, name := range drawOrder() {
 		if actor, ok := charSprite[name]; ok {
+			speaking := CurrentSpeaker == "all" || name == CurrentSpeaker
+			focus := actor.Focus(speaking)
 			// don't recolor actors being faded by animations
 			if !actor.Faded && !actor.Silhouette {
 				// slightly discolor whoever isn't talking
-				if CurrentSpeaker == "all" || name == CurrentSpeaker {
-					actor.SetColorf(1, 1, 1)
-					if DEBUG {
-						actor.SetAlpha(0.7)
-					} else {
-						actor.SetAlpha(1)
-					}
-				} else {
-					actor.SetColorf(0.7, 0.7, 0.7)
-				}
+				tint := dimmedTint(actor, focus)
+				actor.SetColorf(tint.X(), tint.Y(), tint.Z())
 			}
+			// curves that overshoot can take the opacity past what can be drawn
+			alpha := float32(math.Max(0, math.Min(1, float64(actor.Opacity))))
+			if DEBUG && speaking {
+				alpha *= 0.7
+			}
+			actor.SetAlpha(alpha)
 
-			// draw the actor
+			// draw the actor, the speaker can be drawn a little bigger without changing where they rest
+			scale := actor.GetScale()
+			actor.SetScale(scale * (1 + (speakerGrow()-1)*focus))
 			actor.Draw(shaderProgram, proj)
+			actor.SetScale(scale)
 		}
 	}
 }
@@ -772,7 +1042,7 @@ func drawUI(view View, proj hud.Mat4) {
 		} else if v.end.IsAnimating() {
 			v.end.Draw(proj, v.end.GetPosition(), shaderProgram)
 		} else {
-			DrawSprite(v.Sprite, hud.NewMat4(), shaderProgram)
+			DrawSprite(v.Sprite, v.transform, shaderProgram)
 		}
 	}
 
@@ -807,6 +1077,12 @@ func drawText(view View) {
 		var text []string
 		text = append(text, fmt.Sprintf("FPS: %d", FPS))
 		text = append(text, fmt.Sprintf("Volume: (%f)", CurrentBgmVolume))
+		text = append(text, fmt.Sprintf("Voice: (%f)", CurrentVoiceVolume))
+		if loop.Scene.IsPaused() {
+			text = append(text, fmt.Sprintf("Clock: %.3fs (%gx, stopped)", loop.Scene.Now().Seconds(), loop.Scene.Speed()))
+		} else {
+			text = append(text, fmt.Sprintf("Clock: %.3fs (%gx)", loop.Scene.Now().Seconds(), loop.Scene.Speed()))
+		}
 		if sprite, ok := charSprite[CurrentSpeaker]; ok {
 			p := sprite.GetPosition()
 			emoteOffset := p.Sub(Sprites[spriteEmoteBalloon].GetPosition())
@@ -814,6 +1090,9 @@ func drawText(view View) {
 			text = append(text, fmt.Sprintf("scale: (%f)", sprite.GetScale()))
 			text = append(text, fmt.Sprintf("emote: (%f, %f)", emoteOffset.X(), emoteOffset.Y()))
 		}
+		if DEBUG_TEXT != "" {
+			text = append(text, DEBUG_TEXT)
+		}
 		pos := hud.NewSolidText(strings.Join(text, "\n"), hud.COLOR_WHITE, Fonts[fontRegular])
 		pos.SetScale(2)
 		DrawText(view, pos, 0, 0)
@@ -830,10 +1109,35 @@ func keyCallback(window *glfw.Window, key glfw.Key, scancode int, action glfw.Ac
 		return
 	}
 
+	if action != glfw.Release && modalKeyCallback(window, key) {
+		return
+	}
+
+	if key == glfw.KeyP && action == glfw.Press {
+		togglePause()
+		return
+	}
+	if PAUSED {
+		// let go of held keys, everything else waits until the game is resumed
+		if action == glfw.Release {
+			skipKeyCallback(key, action)
+		}
+		return
+	}
+
+	if skipKeyCallback(key, action) {
+		return
+	}
+
 	if action != glfw.Press && action != glfw.Repeat {
 		return
 	}
 
+	if key == glfw.KeyH {
+		openBacklog()
+		return
+	}
+
 	// When a user presses the escape key, we set the WindowShouldClose property to true,
 	// which closes the application
 	if key == glfw.KeyEscape && action == glfw.Press {
@@ -844,8 +1148,19 @@ func keyCallback(window *glfw.Window, key glfw.Key, scancode int, action glfw.Ac
 	}
 	if key == glfw.KeyD {
 		DEBUG = !DEBUG
+		DEBUG_TEXT = ""
+		balloonMoved = false
 		log.Println("debug toggled")
 	}
+	// shift changes the voices instead of the music
+	if key == glfw.KeyEqual && mods&glfw.ModShift != 0 {
+		changeVoiceVolume(0.5)
+		return
+	}
+	if key == glfw.KeyMinus && mods&glfw.ModShift != 0 {
+		changeVoiceVolume(-0.5)
+		return
+	}
 	if key == glfw.KeyEqual {
 		CurrentBgmVolume += 0.5
 		if currentBGM != nil {
@@ -861,6 +1176,38 @@ func keyCallback(window *glfw.Window, key glfw.Key, scancode int, action glfw.Ac
 		fmt.Println("bgm volume:", CurrentBgmVolume)
 	}
 
+	if key == glfw.KeyBackspace {
+		rewind()
+		return
+	}
+
+	if DEBUG && clockKeyCallback(key) {
+		return
+	}
+	if DEBUG && key == glfw.KeyE {
+		openEditor()
+		return
+	}
+
+	// shift + number saves to that slot, alt + number loads it, ctrl is left alone since holding it skips
+	if key >= glfw.Key1 && key <= glfw.Key9 && action == glfw.Press {
+		slot := int(key-glfw.Key1) + 1
+		if mods&glfw.ModShift != 0 {
+			if err := saveGame(slot); err != nil {
+				log.Printf("failed to save slot %d: %v\n", slot, err)
+			} else {
+				log.Printf("saved slot %d\n", slot)
+			}
+			return
+		}
+		if mods&glfw.ModAlt != 0 {
+			if err := loadSave(slot); err != nil {
+				log.Printf("failed to load slot %d: %v\n", slot, err)
+			}
+			return
+		}
+	}
+
 	s, ok := charSprite[CurrentSpeaker]
 	if !ok {
 		return
@@ -890,17 +1237,92 @@ func keyCallback(window *glfw.Window, key glfw.Key, scancode int, action glfw.Ac
 	// move emote balloon
 	if key == glfw.KeyJ {
 		moveActor(Sprites[spriteEmoteBalloon], -0.01, 0)
+		balloonMoved = true
 	}
 	if key == glfw.KeyL {
 		moveActor(Sprites[spriteEmoteBalloon], 0.01, 0)
+		balloonMoved = true
 	}
 	if key == glfw.KeyI {
 		moveActor(Sprites[spriteEmoteBalloon], 0, 0.01)
+		balloonMoved = true
 	}
 	if key == glfw.KeyK {
 		moveActor(Sprites[spriteEmoteBalloon], 0, -0.01)
+		balloonMoved = true
+	}
+
+	// C saves what's on screen to settings.json, with shift the balloon is saved as the head offset
+	if DEBUG && key == glfw.KeyC && action == glfw.Press {
+		if err := commitDebugSettings(mods&glfw.ModShift != 0); err != nil {
+			log.Println("failed to save settings:", err)
+			DEBUG_TEXT = err.Error()
+		} else {
+			DEBUG_TEXT = fmt.Sprintf("saved %s to settings.json", CurrentSpeaker)
+		}
+	}
+}
+
+// modalKeyCallback gives the key to whatever is open over the scene, returns true when something is,
+// nothing behind it gets the key so the scene can't change while it's open
+func modalKeyCallback(window *glfw.Window, key glfw.Key) bool {
+	switch {
+	case menuOpen:
+		menuKeyCallback(window, key)
+	case editorOpen:
+		editorKeyCallback(key)
+	case backlogOpen:
+		backlogKeyCallback(key)
+	default:
+		return false
 	}
+	return true
 }
+
+// modalMouseButton gives the click to whatever is open over the scene, returns true when something is
+func modalMouseButton(button glfw.MouseButton, action glfw.Action, x, y float64) bool {
+	switch {
+	case menuOpen:
+		if action == glfw.Release {
+			menuClick(int(x), int(y))
+		}
+	case editorOpen:
+		editorMouseButton(button, action, x, y)
+	case backlogOpen:
+		if action == glfw.Release {
+			closeBacklog()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// clockKeyCallback changes how fast the scene plays while debugging, returns true when the key was used
+func clockKeyCallback(key glfw.Key) bool {
+	switch key {
+	case glfw.KeyLeftBracket:
+		if speed := loop.Scene.Speed(); speed > 0.125 {
+			loop.Scene.SetSpeed(speed / 2)
+		}
+	case glfw.KeyRightBracket:
+		if speed := loop.Scene.Speed(); speed < 8 {
+			loop.Scene.SetSpeed(speed * 2)
+		}
+	case glfw.KeyPeriod:
+		// stop the clock and move it forward a single frame
+		loop.Scene.Pause()
+		loop.Scene.Step()
+	case glfw.KeyBackslash:
+		loop.Scene.SetSpeed(1)
+		loop.Scene.Resume()
+	default:
+		return false
+	}
+	log.Printf("scene clock: %gx, stopped: %v\n", loop.Scene.Speed(), loop.Scene.IsPaused())
+	return true
+}
+
 func mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
 	if LOADING {
 		return
@@ -909,49 +1331,30 @@ func mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Ac
 	// log.Printf("mouseButtonCallback: button(%v), action(%v)\n", button, action)
 	cursorX, cursorY := glfw.GetCurrentContext().GetCursorPos()
 	fmt.Printf("cursor pos: (%f %f)\n", cursorX, cursorY)
+	if modalMouseButton(button, action, cursorX, cursorY) {
+		return
+	}
+	if action == glfw.Release && PAUSED {
+		resumeGame()
+		return
+	}
 	if action == glfw.Release {
 		if len(reply) > 0 {
-			if len(reply) == 1 {
-				fmt.Println("checking single reply bounds:", rectReplySingle)
-				if inside(rectReplySingle, int(cursorX), int(cursorY)) {
-					fmt.Println("reply hit")
-					if s, ok := Sounds["touch"]; ok {
-						s.Play(CurrentSfxVolume)
-					}
-
-					reply[0].end.Animate(func() {
-						fmt.Println("reply animation ended")
-						delayConfirmation(&status, time.Second)
-						reply[0].Active = false
-					})
+			// once a reply is picked, ignore any other clicks until the script moves on
+			for _, v := range reply {
+				if !v.Active || v.end.IsAnimating() {
 					return
-				} else {
-					fmt.Println("option button not hit:", cursorX, cursorY)
 				}
-			} else if len(reply) == 2 {
-				fmt.Println("checking double reply bounds")
-				if inside(rectReplyDoubleA, int(cursorX), int(cursorY)) {
-					fmt.Println("reply a hit")
-					if s, ok := Sounds["touch"]; ok {
-						s.Play(CurrentSfxVolume)
-					}
-					reply[0].end.Animate(func() {
-						delayConfirmation(&status, time.Second)
-						reply[0].Active = false
-					})
-					return
-				} else if inside(rectReplyDoubleB, int(cursorX), int(cursorY)) {
-					fmt.Println("reply b hit")
-					if s, ok := Sounds["touch"]; ok {
-						s.Play(CurrentSfxVolume)
-					}
-					reply[1].end.Animate(func() {
-						delayConfirmation(&status, time.Second)
-						reply[1].Active = false
-					})
-					return
+			}
+			for i, v := range reply {
+				if !inside(v.Rect, int(cursorX), int(cursorY)) {
+					continue
 				}
+				fmt.Println("reply hit:", i)
+				chooseReply(v)
+				return
 			}
+			fmt.Println("option button not hit:", cursorX, cursorY)
 			return
 		}
 
@@ -960,16 +1363,28 @@ func mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Ac
 		if inside(autoRect, int(cursorX), int(cursorY)) {
 			AUTO = !AUTO
 		} else if inside(menuRect, int(cursorX), int(cursorY)) {
-			fmt.Println("resetting scene")
-			dialogueIndex = 0
-			clear()
-			loadGame(LANDSCAPE_VIEW, scriptName)
+			openMenu()
 		} else {
 			sendConfirmation(&status)
 		}
 	}
 }
 
+// chooseReply plays the reply's closing animation then continues the script from its target
+func chooseReply(r *Reply) {
+	if s, ok := Sounds["touch"]; ok {
+		s.Play(CurrentSfxVolume)
+	}
+
+	recordChoice(r.Text.GetText())
+	r.end.Animate(func() {
+		fmt.Println("reply animation ended")
+		NextLabel = r.Target
+		delayConfirmation(&status, time.Second)
+		r.Active = false
+	})
+}
+
 func queueEvent(event eventFunc) {
 	EventQueue = append(EventQueue, event)
 }
@@ -980,8 +1395,15 @@ func nextDialogue(status *chan uint32) {
 	}
 
 	// fmt.Println("starting dialogue goroutine")
+	pushRewind()
 	releaseReplies()
 
+	// continue from the label a reply picked
+	if NextLabel != "" {
+		jumpToLabel(NextLabel)
+		NextLabel = ""
+	}
+
 	dialogueIndex++
 	if len(Script.Elements) <= dialogueIndex {
 		// *status <- 0
@@ -1003,7 +1425,7 @@ func nextDialogue(status *chan uint32) {
 		// <-time.NewTimer(time.Duration(f) * time.Second).C
 		// fmt.Println("delay ended")
 		// nextDialogue(status)
-		delayNextDialogue(status, time.Duration(f)*time.Second)
+		delayNextDialogue(status, time.Duration(f*float64(time.Second)))
 	case "defect":
 		name := strings.ToLower(element.Mood)
 		if _, ok := Factions[name]; ok {
@@ -1014,13 +1436,24 @@ func nextDialogue(status *chan uint32) {
 	case "clear":
 		clear()
 		nextDialogue(status)
-	case "bg":
-		CurrentBG = element.Mood
+	case layerBackground, layerMidground, layerForeground:
+		if instantTransition(element.Action) {
+			changeBackground(element.Name, element.Mood, element.Action, nil)
+			nextDialogue(status)
+			break
+		}
+		// carry on once the transition has finished
+		changeBackground(element.Name, element.Mood, element.Action, advanceLater(status))
+	case "camera":
+		moveCamera(element)
+		nextDialogue(status)
+	case "layout":
+		setLayout(element.Mood)
 		nextDialogue(status)
 	case "sfx":
 		if s, ok := Sounds[element.Mood]; ok {
 			fmt.Println("playing sfx:", element.Mood)
-			s.Play(CurrentSfxVolume)
+			playSfx(s)
 			nextDialogue(status)
 		}
 	case "bgm":
@@ -1034,9 +1467,7 @@ func nextDialogue(status *chan uint32) {
 		}
 
 		// fade the overlay and continue the script after
-		AsyncSpriteAlpha(fade, status, element.Action != "in", func() {
-			*status <- 2
-		})
+		AsyncSpriteAlpha(fade, status, element.Action != "in", advanceLater(status))
 	case "font":
 		switch element.Mood {
 		case "size":
@@ -1053,10 +1484,36 @@ func nextDialogue(status *chan uint32) {
 		nextDialogue(status)
 	case "sensei":
 		// 2E4152
-		for i, v := range element.Lines {
-			reply = append(reply, createReply(v, i, len(element.Lines)))
+		choices := element.Choices()
+		for i, v := range choices {
+			reply = append(reply, createReply(v, i, len(choices)))
 		}
 		WAITING_CONFIRMATION = true
+		markLineShown()
+	case "label", "endif":
+		nextDialogue(status)
+	case "set":
+		err := State.Apply(element.Mood, element.Action)
+		if err != nil {
+			log.Printf("failed to set %s: %v\n", element.Mood, err)
+		}
+		nextDialogue(status)
+	case "if":
+		ok, err := State.Evaluate(element.Mood, element.Action)
+		if err != nil {
+			log.Printf("failed to check %s: %v\n", element.Mood, err)
+		}
+		if !ok {
+			skipBlock()
+		}
+		nextDialogue(status)
+	case "else":
+		// reaching an else means the if before it ran, so skip to the endif
+		skipBlock()
+		nextDialogue(status)
+	case "jump":
+		jumpToLabel(element.Action)
+		nextDialogue(status)
 	case "none":
 		releaseDialogue()
 		nextDialogue(status)
@@ -1075,17 +1532,28 @@ func prepareActor(status *chan uint32, element script.ScriptElement) {
 		subjectName = nil
 	}
 
+	if element.Voice != "" {
+		playVoice(element.Voice)
+	}
+
 	// only display dialogue if there is dialogue
 	if element.Line != "" && len(element.Lines) > 0 {
 		dialogue = hud.NewText(element.Lines, hud.COLOR_WHITE, Fonts[fontRegular])
 		dialogue.SetScale(CurrentFontSize)
-		dialogue.AsyncAnimate(status)
+		if skipping() {
+			dialogue.Complete()
+		} else {
+			dialogue.AsyncAnimate(status)
+		}
+		skipState.Shown(markRead(element))
+		markLineShown()
+		recordBacklog(element.Name, element.Lines)
 	}
 
 	// if we're affecting all actors, apply the animations and exit
 	if element.Name == "all" {
 		emoteData, emoteSfx := elementToEmoteData(element)
-		if emoteData != nil {
+		if emoteData != nil && !skipping() {
 			for _, actor := range charSprite {
 				actor.AnimateEmote(element.Mood, emoteData, func() {
 					// nextDialogue(status)
@@ -1094,7 +1562,7 @@ func prepareActor(status *chan uint32, element script.ScriptElement) {
 			}
 		}
 		if emoteSfx != nil {
-			emoteSfx.Play(CurrentSfxVolume)
+			playSfx(emoteSfx)
 		}
 
 		// if there's no dialogue but there's an emote, delay the advance
@@ -1114,21 +1582,34 @@ func prepareActor(status *chan uint32, element script.ScriptElement) {
 
 	// do not display sprites if the mood is blank
 	// this indicates that the actor is off screen
-	if element.Mood != "_" && element.Mood != "animation" {
-		charSprite[element.Name] = Actors[element.Name]
+	if putsActorOnStage(element.Mood) {
+		if _, ok := charSprite[element.Name]; !ok {
+			charSprite[element.Name] = Actors[element.Name]
+			updateLayout(Actors[element.Name])
+		}
 	}
 
 	// convert action into predefined parameters
-	shouldChangeSprite, isAnimated := prepareActorAnimation(status, &element, Actors[element.Name], !element.HasDialogue())
+	actor := Actors[element.Name]
+	shouldChangeSprite, isAnimated := prepareActorAnimation(status, &element, actor, !element.HasDialogue())
 
+	// the actor may have already left the stage when the action is an exit
 	if shouldChangeSprite {
-		err := charSprite[element.Name].SetActiveTexture(element.Mood)
+		err := actor.SetActiveTexture(element.Mood)
 		if err != nil {
 			fmt.Println("error loading sprite: ", err.Error())
-			DebugChannel <- fmt.Sprintf("actor (%s) is missing sprite (%s)", element.Name, element.Mood)
+			// a full channel already has something on screen, this message is in the log either way
+			select {
+			case DebugChannel <- fmt.Sprintf("actor (%s) is missing sprite (%s)", element.Name, element.Mood):
+			default:
+			}
 		}
 
-		charSprite[element.Name].Silhouette = false
+		actor.Silhouette = false
+	}
+
+	if element.HasDialogue() {
+		bounceSpeaker(actor)
 	}
 
 	if !element.HasDialogue() && !isAnimated {
@@ -1136,25 +1617,47 @@ func prepareActor(status *chan uint32, element script.ScriptElement) {
 	}
 }
 
+// jumpToLabel moves the script so the line after the label plays next
+func jumpToLabel(label string) {
+	if index, ok := Script.FindLabel(label); ok {
+		dialogueIndex = index
+	} else {
+		log.Printf("no label found with name: %s\n", label)
+	}
+}
+
+// skipBlock moves the script to the end of the [if] or [else] block at the current line
+func skipBlock() {
+	if end, ok := Script.BlockEnd(dialogueIndex); ok {
+		dialogueIndex = end
+	}
+}
+
 func delayNextDialogue(status *chan uint32, duration time.Duration) {
-	go func() {
-		// WAITING_CONFIRMATION = true
-		<-time.NewTimer(duration).C
+	// skip mode doesn't wait for anything
+	if skipping() {
+		duration = 0
+	}
+	loop.After(duration, advanceLater(status))
+}
+
+// advanceLater returns a callback that moves the script on from the main loop,
+// skip mode leaves the script alone from now until it has
+func advanceLater(status *chan uint32) func() {
+	skipState.Queue()
+	return func() {
 		*status <- 2 // send status update to listening channel
-	}()
+	}
 }
 
 func sendConfirmation(status *chan uint32) {
-	go func() {
-		*status <- 3 // send status update to listening channel
-	}()
+	*status <- 3 // send status update to listening channel
 }
 
 func delayConfirmation(status *chan uint32, duration time.Duration) {
-	go func() {
-		<-time.NewTimer(duration).C
+	loop.After(duration, func() {
 		*status <- 3 // send status update to listening channel
-	}()
+	})
 }
 
 // get the emote data from the script element
@@ -1176,27 +1679,50 @@ func elementToEmoteData(element script.ScriptElement) (*hud.AnimatedSprite, *sfx
 	return nil, nil
 }
 
+// elementAction is what an actor marker does and what it does it with,
+// the legacy [<actor> - <emote> - emote] form is read as [<actor> - emote - <emote>]
+func elementAction(element script.ScriptElement) (string, string) {
+	if element.Action == "emote" { // LEGACY
+		return "emote", element.Mood
+	}
+	return element.Mood, element.Action
+}
+
 func prepareActorAnimation(status *chan uint32, element *script.ScriptElement, actor *Actor, autoNextDialogue bool) (bool, bool) {
 
-	action := element.Mood
-	actionName := element.Action
-	if element.Action == "emote" { // LEGACY
-		action = "emote"
-		actionName = element.Mood
+	action, actionName := elementAction(*element)
+
+	// entrances and exits hold the script until the actor is in place or gone, and can change the expression on the way
+	if stage, ok := findStageAction(actionName); ok && action != "emote" {
+		WAITING_CONFIRMATION = true
+		finished := func() {
+			WAITING_CONFIRMATION = false
+			if autoNextDialogue {
+				sendConfirmation(status)
+			}
+		}
+		if stage.enter {
+			enterStage(actor, stage, finished)
+		} else {
+			exitStage(actor, stage, finished)
+		}
+		return action != "_" && action != "animation", true
 	}
 
 	switch action {
 	case "emote":
 		if _, ok := Emotes[actionName]; ok {
 			if emoteData, ok := Emotes[actionName]; ok {
-				actor.AnimateEmote(actionName, emoteData, func() {
-					// nextDialogue(status)
-					fmt.Println("done animating emote")
-				})
+				if !skipping() {
+					actor.AnimateEmote(actionName, emoteData, func() {
+						// nextDialogue(status)
+						fmt.Println("done animating emote")
+					})
+				}
 
 				if s, ok := Sounds[actionName]; ok {
 					fmt.Println("playing sfx:", actionName)
-					s.Play(CurrentSfxVolume)
+					playSfx(s)
 					if autoNextDialogue {
 						delayNextDialogue(status, emoteData.GetDuration())
 					}
@@ -1232,18 +1758,45 @@ func prepareActorAnimation(status *chan uint32, element *script.ScriptElement, a
 		Names[actor.name] = hud.NewSolidText(toTitle(actionName), hud.COLOR_WHITE, Fonts["bold"])
 		Names[actor.name].SetScale(float32(speakerScale))
 		return false, false
+	case "layer":
+		setActorLayer(actor, actionName)
+		return false, false
+	case "stop":
+		if !actor.StopAnimation(actionName) {
+			log.Printf("%s has no animation called \"%s\" to stop\n", actor.name, actionName)
+		}
+		return false, false
+	case "wait":
+		// hold the script until the animation ends, the line carries on by itself when it has no dialogue
+		waiting := actor.WaitForAnimation(actionName, func() {
+			WAITING_CONFIRMATION = false
+			if autoNextDialogue {
+				sendConfirmation(status)
+			}
+		})
+		if waiting {
+			WAITING_CONFIRMATION = true
+		}
+		return false, waiting
 	default: // if the action isn't a special case like emotes, then it's probably a sprite animation
 		// move the actor if the action set the position
+		waits := true
 		if anim, ok := ActorAnimations[actionName]; ok {
 			// fmt.Println("starting animation:", anim.Name)
-			WAITING_CONFIRMATION = anim.Speed < 1
-			go AsyncAnimateActor(actor, anim, status, func() {
+			waits = animationWaits(anim)
+			if waits {
+				WAITING_CONFIRMATION = anim.Speed < 1
+			}
+			actor.PlayAnimation(actionName, anim, func() {
 				// if anim.Speed == 1 {
 				// nextDialogue(status)
 				// } else {
 				// sendConfirmation(status)
 				// }
 				fmt.Println("============= ANIMATION ENDED")
+				if !waits {
+					return
+				}
 				WAITING_CONFIRMATION = false
 				if anim.Speed >= 1 {
 					sendConfirmation(status)
@@ -1251,7 +1804,7 @@ func prepareActorAnimation(status *chan uint32, element *script.ScriptElement, a
 			})
 		}
 		if action == "animation" || action == "_" {
-			return false, true
+			return false, waits
 		}
 		if action == "silhouette" {
 			actor.Silhouette = true
@@ -1291,6 +1844,7 @@ func prepareBgm(element script.ScriptElement, status *chan uint32) {
 			}
 			s.PlayOnRepeat(CurrentBgmVolume)
 			currentBGM = s
+			currentBGMName = bgmAction
 		}
 	}
 }
@@ -1341,6 +1895,16 @@ func AsyncActorColor(s *Actor, status *chan uint32, toBlack bool, done func()) {
 		}
 	}
 
+	// finish straight away while skipping
+	if skipping() {
+		for fadeFunc(0) != -1 {
+		}
+		if done != nil {
+			done()
+		}
+		return
+	}
+
 	loop := loop.New(FRAME_DURATION, fadeFunc, func() {
 		log.Println("finished actor fade")
 		if done != nil {
@@ -1413,6 +1977,16 @@ func AsyncSpriteAlpha(s *hud.Sprite, status *chan uint32, in bool, done func())
 		}
 	}
 
+	// finish straight away while skipping
+	if skipping() {
+		for fadeFunc(0) != -1 {
+		}
+		if done != nil {
+			done()
+		}
+		return
+	}
+
 	loop := loop.New(FRAME_DURATION, fadeFunc, func() {
 		log.Println("finished sprite fade")
 		if done != nil {
@@ -1422,92 +1996,186 @@ func AsyncSpriteAlpha(s *hud.Sprite, status *chan uint32, in bool, done func())
 	loop.Start()
 }
 
-func frameToTargetPosition(frame script.FrameMetadata, center, startingPosition, originalPosition hud.Vec3, originalScale float32) (hud.Vec3, float32) {
-	var targetPosition hud.Vec3
-	targetScale := originalScale
+// frameToTargetPose is where a frame leaves the actor, anything the frame doesn't set carries on from the starting pose
+func frameToTargetPose(frame script.FrameMetadata, center hud.Vec3, starting, original actorPose) actorPose {
+	target := starting
+	target.Scale = original.Scale
 	if frame.Reset {
-		targetPosition = originalPosition
+		return original
 	} else if frame.Center {
-		targetPosition = center
-		targetScale = center.Z()
-	} else {
-		targetPosition = startingPosition
-		if frame.Scale != nil {
-			targetScale = *frame.Scale
-		}
-		if frame.X != nil {
-			targetPosition[0] = *frame.X
-		}
-		if frame.AddX != nil {
-			targetPosition[0] += *frame.AddX
-		}
-		if frame.Y != nil {
-			targetPosition[1] = *frame.Y
-		}
-		if frame.AddY != nil {
-			targetPosition[1] += *frame.AddY
-		}
+		target.Position = center
+		target.Scale = center.Z()
+		return target
 	}
 
-	return targetPosition, targetScale
+	if frame.Scale != nil {
+		target.Scale = *frame.Scale
+	}
+	if frame.X != nil {
+		target.Position[0] = *frame.X
+	}
+	if frame.AddX != nil {
+		target.Position[0] += *frame.AddX
+	}
+	if frame.Y != nil {
+		target.Position[1] = *frame.Y
+	}
+	if frame.AddY != nil {
+		target.Position[1] += *frame.AddY
+	}
+	if frame.Rotation != nil {
+		target.Rotation = *frame.Rotation
+	}
+	if frame.Alpha != nil {
+		target.Opacity = *frame.Alpha
+	}
+	if frame.FlipX != nil {
+		target.FlipX = *frame.FlipX
+	}
+	if frame.Tint != nil {
+		target.Tint = hud.Vec3(*frame.Tint)
+	}
+
+	return target
 }
 
-func AsyncAnimateActor(s *Actor, anim script.AnimationMetadata, status *chan uint32, done func()) {
-	originalPosition := s.GetPosition()
-	originalScale := s.GetScale()
-	// fmt.Println("original position:", originalPosition)
+// AsyncAnimateActor plays the animation's frames on the actor, the loop is returned so it can be stopped early,
+// it's nil when the animation finished straight away.
+// Frames move the actor by how much they change rather than setting where it is, so animations playing at the
+// same time add up, offset keeps count of everything this animation has moved the actor by
+func AsyncAnimateActor(s *Actor, anim script.AnimationMetadata, offset *actorPose, done func()) *loop.GameLoop {
+	if offset == nil {
+		offset = &actorPose{}
+	}
 	speed := anim.Speed
 
-	if speed == 1 {
-		targetPosition, targetScale := frameToTargetPosition(anim.Frames[0], s.GetCenter(), originalPosition, originalPosition, originalScale)
-		s.SetPosition(targetPosition)
-		s.SetScale(targetScale)
+	frame := -1
+	var starting, target, last actorPose
+	var duration, elapsed time.Duration
+	var ease loop.Ease
+	// moveTo puts the animation's part of the pose at p, leaving whatever else is moving the actor alone
+	moveTo := func(p actorPose) {
+		d := subPose(p, last)
+		s.SetPose(addPose(s.GetPose(), d))
+		*offset = addPose(*offset, d)
+		last = p
+	}
+	// nextFrame sets up the move to the next frame, false when there are no frames left
+	nextFrame := func() bool {
+		frame++
+		if frame >= len(anim.Frames) {
+			return false
+		}
+		starting = s.GetPose()
+		last = starting
+		// reset and frames without a scale go back to how the actor was before this animation moved it
+		target = frameToTargetPose(anim.Frames[frame], s.GetCenter(), starting, subPose(starting, *offset))
+		if target.FlipX != starting.FlipX {
+			s.SetFlipX(target.FlipX)
+		}
+		// fmt.Println("animation frame:", frame, "playing animation:", anim.Name, ", position:", s.GetPosition(), "to:", target.Position)
+		duration = frameDuration(anim.Frames[frame], speed)
+		elapsed = 0
+		ease, _ = loop.GetEase(anim.Frames[frame].Easing)
+		if ease == nil {
+			ease = loop.Linear
+		}
+		return true
+	}
+
+	// jump to where the last frame ends when skipping, loops carry on once skipping stops
+	if skipping() && !anim.Loop {
+		for nextFrame() {
+			moveTo(target)
+		}
 		if done != nil {
 			done()
 		}
-		return
+		return nil
 	}
 
-	ticker := time.Tick(FRAME_DURATION)
-	for _, frame := range anim.Frames {
-		startingPosition := s.GetPosition()
-		targetPosition, targetScale := frameToTargetPosition(frame, s.GetCenter(), startingPosition, originalPosition, originalScale)
+	if speed == 1 && !anim.Loop {
+		if nextFrame() {
+			moveTo(target)
+		}
+		if done != nil {
+			done()
+		}
+		return nil
+	}
 
-		// fmt.Println("animation frame:", ind, "playing animation:", anim.Name, ", position:", s.GetPosition(), "to:", targetPosition)
+	if !nextFrame() {
+		if done != nil {
+			done()
+		}
+		return nil
+	}
 
-		// this will move the actor to a target location
-		// this check uses the length of the vector to account for floating point precision issues
+	delayed := false
+	// advance goes on to the next frame, looping animations start again from the first one
+	advance := func() bool {
+		if nextFrame() {
+			return true
+		}
+		if !anim.Loop {
+			return false
+		}
+		frame = -1
+		return nextFrame()
+	}
+	loop := loop.New(FRAME_DURATION, func(f float64) int {
+		if delayed {
+			delayed = false
+			if !advance() {
+				return -1
+			}
+		}
 
-		s.SetScale(targetScale)
+		elapsed += FRAME_DURATION
+		for {
+			progress := 1.0
+			if elapsed < duration {
+				progress = float64(elapsed) / float64(duration)
+			}
 
-		for targetPosition.Sub(s.GetPosition()).Len() > 0.01 {
-			select {
-			case <-ticker:
-				pos := s.GetPosition()
-				diff := targetPosition.Sub(startingPosition)
-				// direction := diff.Normalize()
-				move := diff.Mul(speed)
-				newPos := pos.Add(move)
-				s.SetPositionf(newPos.X(), newPos.Y(), newPos.Z())
-				// fmt.Println("position:", pos, "new position:", newPos, "diff:", targetPosition.Sub(pos), "move:", move)
-				// s.Translate(s.GetPosition().X()-0.1, 0, 0)
-				// fmt.Println("translated X:", s.GetPosition().X())
+			// move everything together along the frame's curve
+			moveTo(lerpPose(starting, target, float32(ease(progress))))
+			if progress < 1 {
+				return int(FRAME_DURATION)
 			}
-		}
 
-		if frame.Delay != nil {
-			// fmt.Println("delaying frame by:", *frame.Delay)
-			time.Sleep(time.Second * time.Duration(*frame.Delay))
+			if d := anim.Frames[frame].Delay; d != nil && *d > 0 {
+				// fmt.Println("delaying frame by:", *d)
+				delayed = true
+				return int(time.Duration(float64(*d) * float64(time.Second)))
+			}
+			wrapped := anim.Loop && frame == len(anim.Frames)-1
+			if !advance() {
+				return -1
+			}
+			// frames without any time to play finish straight away, anything else starts moving next tick,
+			// a loop always waits for the next tick before starting again
+			if duration > 0 || wrapped {
+				return int(FRAME_DURATION)
+			}
 		}
-	}
+	}, func() {
+		if done != nil {
+			done()
+		}
+	})
+	loop.SetEndless(anim.Loop)
+	loop.Start()
+	return loop
+}
 
-	// loop := loop.New(FRAME_DURATION, fadeFunc, func() {
-	// 	log.Println("finished actor fade")
-	if done != nil {
-		done()
+// frameDuration is how long a frame takes to reach its target,
+// without a duration the animation's speed is the fraction of the way it moves every frame
+func frameDuration(frame script.FrameMetadata, speed float32) time.Duration {
+	if frame.Duration != nil {
+		return time.Duration(float64(*frame.Duration) * float64(time.Second))
 	}
-	// })
-	// loop.Start()
+	return FRAME_DURATION * time.Duration(loop.SpeedFrames(float64(speed)))
 }
 
 func AsyncAnimateReply(s *hud.Sprite, status *chan uint32, done func()) {
@@ -1515,26 +2183,31 @@ func AsyncAnimateReply(s *hud.Sprite, status *chan uint32, done func()) {
 	var targetScale float32 = 1.25
 	var speed float32 = 0.01
 
-	ticker := time.Tick(FRAME_DURATION)
-	for s.GetScale() < targetScale {
-		select {
-		case <-ticker:
-			fmt.Println("reply scale:", s.GetScale())
-			s.SetScale(s.GetScale() + speed)
+	loop := loop.New(FRAME_DURATION, func(f float64) int {
+		if s.GetScale() >= targetScale {
+			return -1
 		}
-	}
-
-	if done != nil {
-		done()
-	}
+		fmt.Println("reply scale:", s.GetScale())
+		s.SetScale(s.GetScale() + speed)
+		return 0
+	}, func() {
+		if done != nil {
+			done()
+		}
+	})
+	loop.Start()
 }
 
 /////////////////////////////////////////////
 // HELPER FUNCTIONS
 
 func clear() {
+	for _, actor := range Actors {
+		actor.CancelAnimations()
+	}
 	charSprite = nil
 	charSprite = make(map[string]*Actor, 0)
+	resetLayout()
 	subjectName = nil
 	releaseReplies()
 	if dialogue != nil {
@@ -1636,33 +2309,39 @@ func SaveImage(rgba image.Image, filename string) error {
 		fmt.Println(err.Error())
 		return err
 	}
+	defer out.Close()
 
-	png.Encode(out, rgba)
-	return nil
+	return png.Encode(out, rgba)
 }
 
-func createReply(text string, index, total int) *Reply {
+// replySpacing is how many pixels apart stacked replies are
+const replySpacing = 90
 
-	var textPosition hud.Vec2
-	var yOffset float32
-	var sprite *hud.Sprite
+func createReply(choice script.Choice, index, total int) *Reply {
 
-	txtObj := hud.NewSolidText(text, mgl32.Vec3{0.18, 0.255, 0.322}, Fonts[fontRegular])
-	switch total {
-	case 1:
+	var sprite *hud.Sprite
+	transform := hud.NewMat4()
+
+	// replies are stacked around the middle of the screen, the sprites move in screen space,
+	// which goes from -1 to 1 down the window, so they line up with the text and the click area
+	offset := float32(index) - (float32(total-1) / 2)
+	yOffset := 0.15 - (offset * replySpacing * 2 / float32(LANDSCAPE_VIEW.WindowHeight))
+	rect := rectReplySingle.Add(image.Pt(0, int(offset*replySpacing)))
+
+	txtObj := hud.NewSolidText(choice.Text, mgl32.Vec3{0.18, 0.255, 0.322}, Fonts[fontRegular])
+	textPosition := hud.Vec2{(float32(1280/2) - (txtObj.Width() / 2) + 25), 285 + (offset * replySpacing)}
+	switch {
+	case total == 2 && index == 0:
+		sprite = Sprites[spriteReplyDoubleA]
+		rect = rectReplyDoubleA
+	case total == 2:
+		sprite = Sprites[spriteReplyDoubleB]
+		rect = rectReplyDoubleB
+	default:
+		// the single reply sprite covers the whole screen, move it to where this reply sits
 		sprite = Sprites[spriteReplySingle]
-		yOffset = 0.15
-		textPosition = hud.Vec2{(float32(1280/2) - (txtObj.Width() / 2) + 25), 285}
-	case 2:
-		if index == 0 {
-			sprite = Sprites[spriteReplyDoubleA]
-			yOffset = 0.27
-			textPosition = hud.Vec2{(float32(1280/2) - (txtObj.Width() / 2) + 25), 240}
-		} else {
-			sprite = Sprites[spriteReplyDoubleB]
-			yOffset = 0.03
-			textPosition = hud.Vec2{(float32(1280/2) - (txtObj.Width() / 2) + 25), 330}
-		}
+		transform.Translate(hud.Vec3{0, yOffset - 0.15, 0})
+		transform.Transpose()
 	}
 
 	startAnim := hud.NewAnimation("reply_start", hud.NewAnimatedSpriteFromFile("./resources/ui/reply_start_v3.gif"))
@@ -1673,12 +2352,15 @@ func createReply(text string, index, total int) *Reply {
 	endAnim.SetPositionf(0, yOffset, 0)
 
 	return &Reply{
-		Position: textPosition,
-		Active:   true,
-		Sprite:   sprite,
-		Text:     txtObj,
-		start:    startAnim,
-		end:      endAnim,
+		Position:  textPosition,
+		Active:    true,
+		Sprite:    sprite,
+		Rect:      rect,
+		Target:    choice.Target,
+		transform: transform,
+		Text:      txtObj,
+		start:     startAnim,
+		end:       endAnim,
 	}
 }
 
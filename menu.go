@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/pkg/sfx"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	menuTitleY    = 70
+	menuTop       = 140 // where the first script is drawn, in pixels
+	menuRowHeight = 40
+	menuRows      = 12 // scripts shown at once
+	menuNameX     = 160
+	menuDetailX   = 760
+)
+
+// menuEntry is a script that can be picked from the menu
+type menuEntry struct {
+	Name    string
+	Lines   int
+	ModTime time.Time
+}
+
+type menuText struct {
+	name   *hud.Text
+	detail *hud.Text
+}
+
+var (
+	menuOpen     bool
+	menuEntries  []menuEntry
+	menuSelected int
+	menuScroll   int // index of the first script shown
+	menuTitle    *hud.Text
+	menuPage     []menuText
+	// set when opening the menu paused the game, so closing it knows to resume
+	menuPaused bool
+)
+
+// listScripts reads every script with its size and when it was last saved
+func listScripts() []menuEntry {
+	entries := make([]menuEntry, 0)
+	for _, v := range loadScriptFilenames() {
+		if filepath.Ext(v) != ".txt" {
+			continue
+		}
+		name := strings.TrimSuffix(v, filepath.Ext(v))
+
+		entry := menuEntry{Name: name}
+		path := scriptPath(name)
+		if info, err := os.Stat(path); err == nil {
+			entry.ModTime = info.ModTime()
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			entry.Lines = bytes.Count(data, []byte("\n"))
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				entry.Lines++
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// openMenu shows the script picker, anything playing behind it is paused until it's closed
+func openMenu() {
+	if LOADING || menuOpen {
+		return
+	}
+
+	menuOpen = true
+	closeBacklog()
+	stopSkip()
+	menuPaused = !PAUSED
+	pauseGame()
+
+	menuEntries = listScripts()
+	menuSelected = 0
+	for i, v := range menuEntries {
+		if v.Name == scriptName {
+			menuSelected = i
+		}
+	}
+	menuScroll = 0
+	selectMenuEntry(menuSelected)
+}
+
+func closeMenu() {
+	if !menuOpen {
+		return
+	}
+	menuOpen = false
+	releaseMenuPage()
+	if menuPaused {
+		resumeGame()
+	}
+}
+
+// pickScript closes the menu and loads the script from the start
+func pickScript(name string) {
+	log.Println("picked script:", name)
+	closeMenu()
+	switchScript(name)
+}
+
+// switchScript stops everything from the script being left and starts loading another one
+func switchScript(name string) {
+	clear()
+	resetCamera()
+
+	// stop anything still playing from the script being left
+	sfx.Stop()
+	currentBGM = nil
+	currentBGMName = ""
+
+	scriptName = name
+	loadGame(CURRENT_VIEW, scriptName)
+}
+
+// selectMenuEntry highlights a script, scrolling so it's on screen
+func selectMenuEntry(index int) {
+	if len(menuEntries) == 0 {
+		buildMenuPage()
+		return
+	}
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(menuEntries) {
+		index = len(menuEntries) - 1
+	}
+	menuSelected = index
+
+	if menuSelected < menuScroll {
+		menuScroll = menuSelected
+	}
+	if menuSelected >= menuScroll+menuRows {
+		menuScroll = menuSelected - menuRows + 1
+	}
+	buildMenuPage()
+}
+
+func buildMenuPage() {
+	releaseMenuPage()
+
+	if menuTitle == nil {
+		menuTitle = hud.NewSolidText("Scripts", hud.COLOR_WHITE, Fonts[fontBold])
+		menuTitle.SetScale(float32(speakerScale))
+	}
+
+	if len(menuEntries) == 0 {
+		text := hud.NewSolidText("no scripts found in ./resources/scripts/", hud.COLOR_WHITE, Fonts[fontRegular])
+		text.SetScale(float32(DefaultFontSize))
+		menuPage = append(menuPage, menuText{name: text})
+		return
+	}
+
+	for i := menuScroll; i < len(menuEntries) && i < menuScroll+menuRows; i++ {
+		entry := menuEntries[i]
+
+		color := hud.COLOR_WHITE
+		if i == menuSelected {
+			color = mgl32.Vec3{0.49, 0.81, 1}
+		}
+		name := hud.NewSolidText(entry.Name, color, Fonts[fontBold])
+		name.SetScale(0.9)
+
+		details := fmt.Sprintf("%d lines", entry.Lines)
+		if !entry.ModTime.IsZero() {
+			details = fmt.Sprintf("%s, saved %s", details, entry.ModTime.Format("2006-01-02 15:04"))
+		}
+		detail := hud.NewSolidText(details, color, Fonts[fontRegular])
+		detail.SetScale(float32(DefaultFontSize))
+
+		menuPage = append(menuPage, menuText{name: name, detail: detail})
+	}
+}
+
+func releaseMenuPage() {
+	for _, v := range menuPage {
+		v.name.Release()
+		if v.detail != nil {
+			v.detail.Release()
+		}
+	}
+	menuPage = nil
+}
+
+func drawMenu(view View) {
+	if !menuOpen {
+		return
+	}
+
+	shaderProgram.Use()
+	if backlogShade != nil {
+		DrawSprite(backlogShade, hud.NewMat4(), shaderProgram)
+	}
+
+	if menuTitle != nil {
+		DrawText(view, menuTitle, menuNameX, menuTitleY)
+	}
+	for i, v := range menuPage {
+		y := float32(menuTop + i*menuRowHeight)
+		DrawText(view, v.name, menuNameX, y)
+		if v.detail != nil {
+			DrawText(view, v.detail, menuDetailX, y)
+		}
+	}
+}
+
+// menuRowRect is the clickable area of a row on the page
+func menuRowRect(row int) image.Rectangle {
+	y := menuTop + row*menuRowHeight - menuRowHeight/2
+	return image.Rect(menuNameX-20, y, 1280-menuNameX+20, y+menuRowHeight)
+}
+
+// menuKeyCallback handles input while the menu is open
+func menuKeyCallback(window *glfw.Window, key glfw.Key) {
+	switch key {
+	case glfw.KeyEscape:
+		if Script == nil || len(Script.Elements) == 0 {
+			// there's nothing to go back to
+			window.SetShouldClose(true)
+		} else {
+			closeMenu()
+		}
+	case glfw.KeyUp:
+		selectMenuEntry(menuSelected - 1)
+	case glfw.KeyDown:
+		selectMenuEntry(menuSelected + 1)
+	case glfw.KeyPageUp:
+		selectMenuEntry(menuSelected - menuRows)
+	case glfw.KeyPageDown:
+		selectMenuEntry(menuSelected + menuRows)
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		if menuSelected < len(menuEntries) {
+			pickScript(menuEntries[menuSelected].Name)
+		}
+	}
+}
+
+// menuClick picks the script under the cursor
+func menuClick(x, y int) {
+	for row := range menuPage {
+		index := menuScroll + row
+		if index >= len(menuEntries) || !inside(menuRowRect(row), x, y) {
+			continue
+		}
+		pickScript(menuEntries[index].Name)
+		break
+	}
+}
+
+// menuScrollWheel scrolls the list, returns true when the menu is open
+func menuScrollWheel(yoff float64) bool {
+	if !menuOpen {
+		return false
+	}
+
+	if yoff > 0 {
+		selectMenuEntry(menuSelected - 1)
+	} else if yoff < 0 {
+		selectMenuEntry(menuSelected + 1)
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/pkg/sfx"
+)
+
+var (
+	PAUSED    = false
+	pauseText *hud.Text
+	// the clock may already be stopped from debugging, resuming shouldn't start it again
+	clockWasStopped bool
+)
+
+func togglePause() {
+	if PAUSED {
+		resumeGame()
+	} else {
+		pauseGame()
+	}
+}
+
+// pauseGame freezes the scene clock and every sound, so the typewriter, tweens, fades, emotes and timers all stop where they are
+func pauseGame() {
+	if LOADING || PAUSED {
+		return
+	}
+	PAUSED = true
+	stopSkip()
+
+	clockWasStopped = loop.Scene.IsPaused()
+	loop.Scene.Pause()
+	sfx.PauseAll()
+}
+
+func resumeGame() {
+	if !PAUSED {
+		return
+	}
+	PAUSED = false
+
+	if !clockWasStopped {
+		loop.Scene.Resume()
+	}
+	sfx.ResumeAll()
+}
+
+func drawPause(view View) {
+	// the menu covers the screen while it has the game paused
+	if !PAUSED || menuOpen {
+		return
+	}
+
+	shaderProgram.Use()
+	if backlogShade != nil {
+		DrawSprite(backlogShade, hud.NewMat4(), shaderProgram)
+	}
+	if pauseText == nil {
+		pauseText = hud.NewSolidText("PAUSED", hud.COLOR_WHITE, Fonts[fontBold])
+		pauseText.SetScale(2)
+	}
+	DrawText(view, pauseText, (float32(view.WindowWidth)-pauseText.Width())/2, float32(view.WindowHeight)/2-30)
+}
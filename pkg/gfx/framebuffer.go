@@ -0,0 +1,78 @@
+package gfx
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Framebuffer is an offscreen render target, anything drawn while it's bound can be read back as an image
+type Framebuffer struct {
+	handle       uint32
+	renderbuffer uint32
+	width        int
+	height       int
+}
+
+func NewFramebuffer(width, height int) (*Framebuffer, error) {
+	fb := &Framebuffer{
+		width:  width,
+		height: height,
+	}
+
+	gl.GenFramebuffers(1, &fb.handle)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fb.handle)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	gl.GenRenderbuffers(1, &fb.renderbuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, fb.renderbuffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.RGBA8, int32(width), int32(height))
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, fb.renderbuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		fb.Delete()
+		return nil, fmt.Errorf("framebuffer is incomplete, status: 0x%x", status)
+	}
+	return fb, nil
+}
+
+// Bind makes everything draw into the framebuffer instead of the window
+func (fb *Framebuffer) Bind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fb.handle)
+	gl.Viewport(0, 0, int32(fb.width), int32(fb.height))
+}
+
+func (fb *Framebuffer) UnBind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// ReadImage copies what has been drawn, the framebuffer needs to be bound
+func (fb *Framebuffer) ReadImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, fb.width, fb.height))
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, int32(fb.width), int32(fb.height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+
+	// OpenGL starts from the bottom row, flip it so the image is the right way up
+	stride := img.Stride
+	row := make([]byte, stride)
+	for top, bottom := 0, fb.height-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a := img.Pix[top*stride : (top+1)*stride]
+		b := img.Pix[bottom*stride : (bottom+1)*stride]
+		copy(row, a)
+		copy(a, b)
+		copy(b, row)
+	}
+
+	// the scene is drawn with blending, so the alpha left behind isn't meant to be seen
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func (fb *Framebuffer) Delete() {
+	gl.DeleteRenderbuffers(1, &fb.renderbuffer)
+	gl.DeleteFramebuffers(1, &fb.handle)
+}
@@ -4,12 +4,15 @@ import (
 	"errors"
 	"image"
 	"image/draw"
+	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"os"
 
 	"github.com/disintegration/imaging"
 	"github.com/go-gl/gl/v4.1-core/gl"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
 )
 
 type Texture struct {
@@ -0,0 +1,120 @@
+package sfx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// size of the header written before the samples of a wav file
+const wavHeaderSize = 44
+
+// Recorder writes everything played after InitRecording to a 16 bit stereo wav file
+type Recorder struct {
+	file    *os.File
+	samples int
+	buffer  [][2]float64
+	data    []byte
+}
+
+func NewRecorder(filename string) (*Recorder, error) {
+	if !recording {
+		return nil, fmt.Errorf("audio can't be recorded before InitRecording is called")
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{file: f}
+	// the sizes in the header are filled in once recording is finished
+	err = r.writeHeader()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record takes the next n samples of the mix and writes them to the file
+func (r *Recorder) Record(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if cap(r.buffer) < n {
+		r.buffer = make([][2]float64, n)
+		r.data = make([]byte, n*4)
+	}
+	buffer := r.buffer[:n]
+	data := r.data[:n*4]
+
+	output.Stream(buffer)
+	for i, v := range buffer {
+		binary.LittleEndian.PutUint16(data[i*4:], uint16(toInt16(v[0])))
+		binary.LittleEndian.PutUint16(data[i*4+2:], uint16(toInt16(v[1])))
+	}
+
+	_, err := r.file.Write(data)
+	if err != nil {
+		return err
+	}
+	r.samples += n
+	return nil
+}
+
+// Close finishes the header and closes the file
+func (r *Recorder) Close() error {
+	_, err := r.file.Seek(0, io.SeekStart)
+	if err == nil {
+		err = r.writeHeader()
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *Recorder) writeHeader() error {
+	const channels = 2
+	const bytesPerSample = 2
+
+	dataSize := uint32(r.samples * channels * bytesPerSample)
+	header := struct {
+		ChunkID       [4]byte
+		ChunkSize     uint32
+		Format        [4]byte
+		Subchunk1ID   [4]byte
+		Subchunk1Size uint32
+		AudioFormat   uint16
+		NumChannels   uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Subchunk2ID   [4]byte
+		Subchunk2Size uint32
+	}{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     wavHeaderSize - 8 + dataSize,
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   1, // PCM
+		NumChannels:   channels,
+		SampleRate:    uint32(SampleRate),
+		ByteRate:      uint32(SampleRate) * channels * bytesPerSample,
+		BlockAlign:    channels * bytesPerSample,
+		BitsPerSample: bytesPerSample * 8,
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: dataSize,
+	}
+	return binary.Write(r.file, binary.LittleEndian, header)
+}
+
+func toInt16(v float64) int16 {
+	v = math.Max(-1, math.Min(1, v))
+	return int16(v * math.MaxInt16)
+}
@@ -3,6 +3,7 @@ package sfx
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
@@ -22,8 +23,24 @@ type Streamer struct {
 	file   *os.File
 	Base   float64
 	Silent bool
+
+	// the value of cleared when the streamer was last played
+	played int
 }
 
+// SampleRate is what every file is played at, files with a different rate will play at the wrong speed
+const SampleRate = beep.SampleRate(48000)
+
+var (
+	// everything playing is mixed here, then goes through output so it can all be paused at once
+	mixer  = &beep.Mixer{}
+	output = &beep.Ctrl{Streamer: mixer}
+	// set while the audio is being written to a file instead of played
+	recording bool
+	// counts every Stop, anything played before the last one has been cut off
+	cleared int
+)
+
 func Init() error {
 	// fmt.Println("----- initializing sfx -----")
 	// fmt.Println("sample rate:", s.format.SampleRate)
@@ -35,7 +52,49 @@ func Init() error {
 	// sfx
 	// sample rate: 22050
 	// buffer size: 734
-	return speaker.Init(48000, 3000) //s.format.SampleRate.N(time.Second/30))
+	err := speaker.Init(SampleRate, 3000) //s.format.SampleRate.N(time.Second/30))
+	if err != nil {
+		return err
+	}
+	speaker.Play(output)
+	return nil
+}
+
+// InitRecording mixes everything that plays without sending it to the speaker,
+// a Recorder takes the mixed audio out as the scene moves forward
+func InitRecording() {
+	recording = true
+}
+
+func play(s beep.Streamer) {
+	lock()
+	mixer.Add(s)
+	unlock()
+}
+
+// while recording, the mix is only used from the main thread, so it doesn't need the speaker lock
+func lock() {
+	if !recording {
+		speaker.Lock()
+	}
+}
+func unlock() {
+	if !recording {
+		speaker.Unlock()
+	}
+}
+
+// PauseAll holds every sound where it is, ResumeAll carries on from the same spot
+func PauseAll() {
+	lock()
+	output.Paused = true
+	unlock()
+}
+
+func ResumeAll() {
+	lock()
+	output.Paused = false
+	unlock()
 }
 
 func NewStreamer(filename string) (*Streamer, error) {
@@ -68,7 +127,7 @@ func NewStreamer(filename string) (*Streamer, error) {
 }
 
 func (s *Streamer) Play(volume float64) {
-	speaker.Lock()
+	lock()
 	// reinitialize the streamer so that we don't have to keep the file open
 	// buff := io.NopCloser(bytes.NewReader(s.data))
 	// streamer, _, err := mp3.Decode(buff)
@@ -79,20 +138,26 @@ func (s *Streamer) Play(volume float64) {
 	// when the file isn't open, seek will panic
 	// @TODO: find out if there's a way to seek from a buffer
 	s.Seek(0)
-	speaker.Unlock()
+	unlock()
 
-	s.ctrl = nil
+	s.ctrl = &beep.Ctrl{Streamer: s.StreamSeekCloser}
+	s.played = cleared
 	s.controller = &effects.Volume{
-		Streamer: s.StreamSeekCloser,
+		Streamer: s.ctrl,
 		Base:     s.Base,
 		Volume:   volume,
 		Silent:   s.Silent,
 	}
-	speaker.Play(s.controller)
+	play(s.controller)
 }
 
 func (s *Streamer) PlayOnRepeat(volume float64) {
-	speaker.Lock()
+	s.PlayOnRepeatFrom(volume, 0)
+}
+
+// PlayOnRepeatFrom loops the streamer starting part way through the file
+func (s *Streamer) PlayOnRepeatFrom(volume float64, position time.Duration) {
+	lock()
 	// reinitialize the streamer so that we don't have to keep the file open
 	// buff := io.NopCloser(bytes.NewReader(s.data))
 	// streamer, _, err := mp3.Decode(buff)
@@ -100,22 +165,47 @@ func (s *Streamer) PlayOnRepeat(volume float64) {
 	// 	log.Fatal(err)
 	// }
 	// s.StreamSeekCloser = streamer
-	s.Seek(0)
-	speaker.Unlock()
+	start := s.format.SampleRate.N(position)
+	if start < 0 || start >= s.Len() {
+		start = 0
+	}
+	s.Seek(start)
+	unlock()
 
 	// @TODO: this is digsuting, come up with a way to fix it
 	s.ctrl = &beep.Ctrl{Streamer: beep.Loop(-1, s.StreamSeekCloser), Paused: false}
+	s.played = cleared
 	s.controller = &effects.Volume{
 		Streamer: s.ctrl,
 		Base:     s.Base,
 		Volume:   volume,
 		Silent:   s.Silent,
 	}
-	speaker.Play(s.controller)
+	play(s.controller)
 }
 
 func Stop() {
-	speaker.Clear()
+	lock()
+	mixer.Clear()
+	cleared++
+	unlock()
+}
+
+// Stop cuts the streamer off where it is, everything else carries on playing
+func (s *Streamer) Stop() {
+	lock()
+	if s.ctrl != nil {
+		s.ctrl.Streamer = nil
+	}
+	unlock()
+}
+
+// IsPlaying is true until the streamer gets to the end of the file or is stopped,
+// a paused streamer is still playing
+func (s *Streamer) IsPlaying() bool {
+	lock()
+	defer unlock()
+	return s.ctrl != nil && s.ctrl.Streamer != nil && s.played == cleared && s.Position() < s.Len()
 }
 
 func (s *Streamer) Release() {
@@ -123,14 +213,18 @@ func (s *Streamer) Release() {
 }
 
 func Close() {
+	if recording {
+		recording = false
+		return
+	}
 	speaker.Close()
 }
 
 func (s *Streamer) SetVolume(volume float64) {
 	if s.controller != nil {
-		speaker.Lock()
+		lock()
 		s.controller.Volume = volume
-		speaker.Unlock()
+		unlock()
 	}
 }
 
@@ -141,6 +235,17 @@ func (s *Streamer) GetVolume() float64 {
 	return 0
 }
 
+// GetPosition returns how far the streamer has played into the file
+func (s *Streamer) GetPosition() time.Duration {
+	lock()
+	defer unlock()
+	return s.format.SampleRate.D(s.Position())
+}
+
+func (s *Streamer) IsPaused() bool {
+	return s.ctrl != nil && s.ctrl.Paused
+}
+
 func (s *Streamer) Resume() {
 	if s.ctrl != nil {
 		s.ctrl.Paused = false
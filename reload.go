@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+const (
+	settingsFile = "./resources/settings.json"
+	// how often the script and settings are checked for changes
+	reloadInterval = 500 * time.Millisecond
+)
+
+var (
+	scriptModTime   time.Time
+	settingsModTime time.Time
+	// set when a change was found while the scene couldn't be reloaded yet
+	reloadPending bool
+)
+
+func scriptPath(name string) string {
+	return fmt.Sprintf("./resources/scripts/%s.txt", name)
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// watchFiles remembers when the script and settings were last changed, so only edits made after this are reloaded
+func watchFiles() {
+	scriptModTime = modTime(scriptPath(scriptName))
+	settingsModTime = modTime(settingsFile)
+	reloadPending = false
+}
+
+// checkForChanges reloads the script and settings when either has been saved since they were loaded,
+// it returns true when it tried, along with any problems that should stay on screen
+func checkForChanges() (bool, string) {
+	// the editor has actors posed away from the scene, changes are picked up once it's closed
+	if LOADING || pendingSave != nil || scriptName == "" || editorOpen {
+		return false, ""
+	}
+
+	scriptTime := modTime(scriptPath(scriptName))
+	settingsTime := modTime(settingsFile)
+	if scriptTime.Equal(scriptModTime) && settingsTime.Equal(settingsModTime) && !reloadPending {
+		return false, ""
+	}
+
+	// actors still moving would keep going after the scene is put back, wait for them to finish
+	if WAITING_CONFIRMATION && len(reply) == 0 {
+		reloadPending = true
+		return false, ""
+	}
+
+	scriptModTime = scriptTime
+	settingsModTime = settingsTime
+	reloadPending = false
+	return true, reloadScript()
+}
+
+// reloadScript swaps in the script and settings from disk without leaving the line on screen,
+// a script with errors is reported and the one already playing is kept
+func reloadScript() string {
+	log.Println("reloading", scriptName)
+
+	s, err := script.NewScriptFromFile(scriptPath(scriptName))
+	if err != nil {
+		return errorsToString([]error{err})
+	}
+	for _, v := range s.Diagnostics {
+		log.Println(v)
+	}
+	metadata, err := script.LoadMetadata(settingsFile)
+	if err == script.ErrOldSettings {
+		log.Println(err)
+	} else if err != nil {
+		return errorsToString([]error{err})
+	}
+
+	loads, problems := queueScriptResources(s, metadata)
+	if len(problems) > 0 {
+		return errorsToString([]error{problems})
+	}
+
+	// only load what wasn't needed before
+	missing := make([]error, 0)
+	for _, v := range loads {
+		if v.Category == "actor" {
+			if actor, ok := Actors[v.Object]; ok {
+				if _, ok := actor.GetTextures()[v.Key]; ok {
+					continue
+				}
+			}
+		}
+		if err := loadResource(v); err != nil {
+			missing = append(missing, err)
+		}
+	}
+
+	save := captureSave()
+	if dialogueIndex >= 0 && dialogueIndex < len(Script.Elements) {
+		save.Index = s.Nearest(Script.Get(dialogueIndex), dialogueIndex)
+	}
+
+	// earlier lines can still be rewound to, as long as they can be found in the new script
+	for i, v := range rewindHistory {
+		if v.save.Index >= 0 && v.save.Index < len(Script.Elements) {
+			rewindHistory[i].save.Index = s.Nearest(Script.Get(v.save.Index), v.save.Index)
+		}
+	}
+
+	Script = s
+	applyMetadata(metadata)
+	NextLabel = ""
+
+	// the line is recorded again once it's back on screen
+	if lineShown {
+		backlog = backlog[:lineShownBacklog]
+	}
+	restoreSave(save)
+	log.Printf("reloaded %s, continuing from element %d\n", scriptName, save.Index)
+
+	return strings.TrimSpace(errorsToString(missing))
+}
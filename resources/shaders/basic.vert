@@ -5,6 +5,7 @@ layout (location = 1) in vec3 color;
 layout (location = 2) in vec2 texCoord;
 
 uniform mat4 prjMatrix;
+uniform bool flipX; // mirror the texture so the sprite faces the other way
 //mat4 prjMatrix = mat4(
 //    1, 0, 0, 0,
 //	0, 1, 0, 0,
@@ -20,4 +21,7 @@ void main()
     gl_Position = vec4(position, 1.0) * prjMatrix;
     ourColor = color;       // pass the color on to the fragment shader
     TexCoord = texCoord;    // pass the texture coords on to the fragment shader
+    if (flipX) {
+        TexCoord.x = 1.0 - TexCoord.x;
+    }
 }
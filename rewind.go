@@ -0,0 +1,68 @@
+package main
+
+import "log"
+
+// how many lines back the scene can be rewound
+const maxRewindHistory = 500
+
+// rewindPoint is the scene of a line the player has already moved past
+type rewindPoint struct {
+	save    *SaveData
+	backlog int // backlog length before the line was recorded
+}
+
+var (
+	rewindHistory = make([]rewindPoint, 0)
+	// set while a line is on screen, so advancing past it knows to take a snapshot
+	lineShown        bool
+	lineShownBacklog int
+)
+
+// markLineShown is called when a line stops the script to be read or answered
+func markLineShown() {
+	lineShown = true
+	lineShownBacklog = len(backlog)
+}
+
+// pushRewind takes a snapshot of the line on screen before the script moves past it
+func pushRewind() {
+	if !lineShown {
+		return
+	}
+	lineShown = false
+
+	rewindHistory = append(rewindHistory, rewindPoint{
+		save:    captureSave(),
+		backlog: lineShownBacklog,
+	})
+	if len(rewindHistory) > maxRewindHistory {
+		rewindHistory = rewindHistory[len(rewindHistory)-maxRewindHistory:]
+	}
+}
+
+func resetRewind() {
+	rewindHistory = make([]rewindPoint, 0)
+	lineShown = false
+}
+
+// rewind puts the scene back to how it was on the previous line
+func rewind() {
+	if LOADING || len(rewindHistory) == 0 {
+		return
+	}
+	// an animation still running would keep moving actors after they're put back
+	if WAITING_CONFIRMATION && len(reply) == 0 {
+		log.Println("can't rewind while an animation is playing")
+		return
+	}
+
+	point := rewindHistory[len(rewindHistory)-1]
+	rewindHistory = rewindHistory[:len(rewindHistory)-1]
+
+	closeBacklog()
+	if point.backlog < len(backlog) {
+		backlog = backlog[:point.backlog]
+	}
+	NextLabel = ""
+	restoreSave(point.save)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/pkg/sfx"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const saveDirectory = "./saves"
+
+// a save loaded from disk waits here until its script has finished loading
+var pendingSave *SaveData
+
+// SaveData is everything needed to rebuild the scene at a line without replaying the script
+type SaveData struct {
+	Script     string               `json:"script"`
+	Index      int                  `json:"index"`
+	SavedAt    time.Time            `json:"saved_at"`
+	Background string               `json:"background"`
+	Layers     map[string]string    `json:"layers,omitempty"` // mid-ground and foreground
+	Speaker    string               `json:"speaker"`
+	FontSize   float32              `json:"font_size"`
+	Bgm        *BgmSave             `json:"bgm,omitempty"`
+	Overlay    OverlaySave          `json:"overlay"`
+	Actors     map[string]ActorSave `json:"actors"`
+	Names      map[string]string    `json:"names"`
+	Factions   map[string]*string   `json:"factions"` // nil when the faction was removed
+	Variables  map[string]string    `json:"variables"`
+	Camera     *cameraState         `json:"camera,omitempty"`
+	Layout     bool                 `json:"layout,omitempty"`
+	StageOrder []string             `json:"stage_order,omitempty"`
+}
+type BgmSave struct {
+	Name     string        `json:"name"`
+	Position time.Duration `json:"position"`
+	Volume   float64       `json:"volume"`
+	Paused   bool          `json:"paused"`
+}
+type OverlaySave struct {
+	Texture string  `json:"texture"`
+	Alpha   float32 `json:"alpha"`
+}
+type ActorSave struct {
+	Texture    string   `json:"texture"`
+	Position   hud.Vec3 `json:"position"`
+	Scale      float32  `json:"scale"`
+	Color      hud.Vec3 `json:"color"`
+	Alpha      float32  `json:"alpha"`
+	Silhouette bool     `json:"silhouette"`
+	Faded      bool     `json:"faded"`
+	// left by animations, older saves don't have them
+	Rotation float32   `json:"rotation,omitempty"`
+	FlipX    bool      `json:"flip_x,omitempty"`
+	Opacity  *float32  `json:"opacity,omitempty"`
+	Tint     *hud.Vec3 `json:"tint,omitempty"`
+	// looping animations by track, everything else above is where the actor rests without them
+	Loops map[string]string `json:"loops,omitempty"`
+	Layer *int              `json:"layer,omitempty"` // older saves use the layer from settings.json
+}
+
+func saveSlotPath(slot int) string {
+	return filepath.Join(saveDirectory, fmt.Sprintf("slot_%d.json", slot))
+}
+
+// captureSave records the scene as it is right now
+func captureSave() *SaveData {
+	save := &SaveData{
+		Script:     scriptName,
+		Index:      dialogueIndex,
+		SavedAt:    time.Now(),
+		Background: bgLayers[layerBackground].name,
+		Layers:     captureLayers(),
+		Speaker:    CurrentSpeaker,
+		FontSize:   CurrentFontSize,
+		Actors:     make(map[string]ActorSave),
+		Names:      make(map[string]string),
+		Factions:   make(map[string]*string),
+		Variables:  make(map[string]string),
+		// a move still going is saved as finished
+		Camera:     &cameraState{Zoom: cameraGoal.Zoom, Center: cameraGoal.Center},
+		Layout:     layoutAuto,
+		StageOrder: append([]string{}, stageOrder...),
+	}
+
+	if currentBGM != nil {
+		save.Bgm = &BgmSave{
+			Name:     currentBGMName,
+			Position: currentBGM.GetPosition(),
+			Volume:   currentBGM.GetVolume(),
+			Paused:   currentBGM.IsPaused(),
+		}
+	}
+	if fade != nil {
+		save.Overlay = OverlaySave{
+			Texture: fade.GetActiveTextureKey(),
+			Alpha:   fade.GetAlpha(),
+		}
+	}
+	for name, actor := range charSprite {
+		pose := actor.RestingPose()
+		layer := actor.Layer
+		save.Actors[name] = ActorSave{
+			Texture:    actor.GetActiveTextureKey(),
+			Position:   pose.Position,
+			Scale:      pose.Scale,
+			Color:      actor.GetColor(),
+			Alpha:      actor.GetAlpha(),
+			Silhouette: actor.Silhouette,
+			Faded:      actor.Faded,
+			Rotation:   pose.Rotation,
+			FlipX:      pose.FlipX,
+			Opacity:    &pose.Opacity,
+			Tint:       &pose.Tint,
+			Loops:      actor.Loops(),
+			Layer:      &layer,
+		}
+	}
+	for name, text := range Names {
+		save.Names[name] = text.GetText()
+	}
+	for name, text := range Factions {
+		if text == nil {
+			save.Factions[name] = nil
+			continue
+		}
+		faction := text.GetText()
+		save.Factions[name] = &faction
+	}
+	for k, v := range State.Variables {
+		save.Variables[k] = v
+	}
+
+	return save
+}
+
+func saveGame(slot int) error {
+	if LOADING || Script == nil || dialogueIndex < 0 {
+		return fmt.Errorf("nothing to save yet")
+	}
+
+	data, err := json.MarshalIndent(captureSave(), "", "	")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(saveDirectory, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(saveSlotPath(slot), data, 0644)
+}
+
+func readSave(slot int) (*SaveData, error) {
+	data, err := os.ReadFile(saveSlotPath(slot))
+	if err != nil {
+		return nil, err
+	}
+
+	var save SaveData
+	err = json.Unmarshal(data, &save)
+	if err != nil {
+		return nil, fmt.Errorf("save slot %d is corrupted: %v", slot, err)
+	}
+	return &save, nil
+}
+
+// loadSave reloads the save's script, the scene is rebuilt once its resources are loaded
+func loadSave(slot int) error {
+	if LOADING {
+		return fmt.Errorf("already loading")
+	}
+
+	save, err := readSave(slot)
+	if err != nil {
+		return err
+	}
+
+	pendingSave = save
+	switchScript(save.Script)
+	return nil
+}
+
+// restoreSave rebuilds the scene from a save, it must run on the main thread after loading has finished
+func restoreSave(save *SaveData) {
+	clear()
+
+	WAITING_CONFIRMATION = false
+	// anything the old scene was going to move on from is gone with it
+	skipState.Advanced()
+	lineShown = false
+	restoreLayers(save.Background, save.Layers)
+	CurrentFontSize = save.FontSize
+	State.Variables = make(map[string]string)
+	for k, v := range save.Variables {
+		State.Variables[k] = v
+	}
+
+	for name, a := range save.Actors {
+		actor, ok := Actors[name]
+		if !ok {
+			log.Printf("save has an actor that isn't in the script: %s\n", name)
+			continue
+		}
+		if err := actor.SetActiveTexture(a.Texture); err != nil {
+			log.Println(err)
+		}
+		actor.SetPosition(a.Position)
+		actor.SetScale(a.Scale)
+		actor.SetColorf(a.Color.X(), a.Color.Y(), a.Color.Z())
+		actor.SetAlpha(a.Alpha)
+		actor.Silhouette = a.Silhouette
+		actor.Faded = a.Faded
+		actor.SetRotation(a.Rotation)
+		actor.SetFlipX(a.FlipX)
+		actor.Opacity = 1
+		if a.Opacity != nil {
+			actor.Opacity = *a.Opacity
+		}
+		actor.Tint = hud.Vec3{1, 1, 1}
+		if a.Tint != nil {
+			actor.Tint = *a.Tint
+		}
+		actor.Layer = actor.defaultLayer
+		if a.Layer != nil {
+			actor.Layer = *a.Layer
+		}
+		charSprite[name] = actor
+		actor.StartLoops(a.Loops)
+	}
+	// everyone is already standing in their slot from the save
+	layoutAuto = save.Layout
+	stageOrder = append([]string{}, save.StageOrder...)
+	syncStageOrder()
+	for name, text := range save.Names {
+		Names[name] = hud.NewSolidText(text, hud.COLOR_WHITE, Fonts[fontBold])
+		Names[name].SetScale(float32(speakerScale))
+	}
+	for name, text := range save.Factions {
+		if text == nil {
+			Factions[name] = nil
+			continue
+		}
+		Factions[name] = hud.NewSolidText(*text, mgl32.Vec3{0.49, 0.81, 1}, Fonts[fontBold])
+		Factions[name].SetScale(0.8)
+	}
+
+	if fade != nil && save.Overlay.Texture != "" {
+		fade.SetActiveTexture(save.Overlay.Texture)
+		fade.SetAlpha(save.Overlay.Alpha)
+	}
+
+	restoreBgm(save.Bgm)
+	restoreCamera(save.Camera)
+
+	restoreLine(save)
+}
+
+// restoreBgm only restarts the music when it isn't already the track that was saved
+func restoreBgm(bgm *BgmSave) {
+	if bgm != nil && currentBGM != nil && bgm.Name == currentBGMName {
+		currentBGM.SetVolume(bgm.Volume)
+		if bgm.Paused {
+			currentBGM.Pause()
+		} else {
+			currentBGM.Resume()
+		}
+		return
+	}
+
+	if currentBGM != nil {
+		sfx.Stop()
+		currentBGM = nil
+		currentBGMName = ""
+	}
+	if bgm == nil {
+		return
+	}
+	if s, ok := Sounds[bgm.Name]; ok {
+		s.PlayOnRepeatFrom(bgm.Volume, bgm.Position)
+		if bgm.Paused {
+			s.Pause()
+		}
+		currentBGM = s
+		currentBGMName = bgm.Name
+	}
+}
+
+// restoreLine puts the saved line back on screen without replaying its animations
+func restoreLine(save *SaveData) {
+	dialogueIndex = save.Index
+	if dialogueIndex < 0 || dialogueIndex >= len(Script.Elements) {
+		return
+	}
+
+	element := Script.Get(dialogueIndex)
+	switch element.Name {
+	case "sensei":
+		// replay the line so the reply buttons come back
+		dialogueIndex--
+		nextDialogue(&status)
+		return
+	}
+
+	CurrentSpeaker = save.Speaker
+	if t, ok := Names[CurrentSpeaker]; ok {
+		subjectName = t
+	}
+	if element.Line != "" && len(element.Lines) > 0 {
+		dialogue = hud.NewText(element.Lines, hud.COLOR_WHITE, Fonts[fontRegular])
+		dialogue.SetScale(CurrentFontSize)
+		dialogue.Complete()
+		markLineShown()
+		recordBacklog(CurrentSpeaker, element.Lines)
+	}
+}
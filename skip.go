@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BlunterMonk/our_archive/internal/hud"
+	"github.com/BlunterMonk/our_archive/internal/script"
+	"github.com/BlunterMonk/our_archive/pkg/sfx"
+	"github.com/go-gl/glfw/v3.2/glfw"
+)
+
+var (
+	SKIP     = false // toggled with F
+	skipHeld bool    // set while ctrl is held down
+	skipText *hud.Text
+
+	// lines that have been read before, by script name, kept between runs so skip mode knows where to stop
+	readHistory = make(map[string]map[string]bool)
+	readLoaded  bool
+	// whether the line on screen is unread and whether the script is about to move on by itself
+	skipState script.Skip
+)
+
+func readHistoryPath() string {
+	return filepath.Join(saveDirectory, "read.json")
+}
+
+// skipping is true while the script should fast-forward
+func skipping() bool {
+	return (SKIP || skipHeld) && !LOADING
+}
+
+// the line on screen has been seen by the time skip is turned on, so don't stop on it
+func startSkip() {
+	skipState.Start()
+}
+
+func stopSkip() {
+	SKIP = false
+	skipHeld = false
+}
+
+// skipStep advances the script by a line each frame while skipping,
+// it stops at replies, at the end of the script, and at lines that haven't been read yet
+func skipStep() {
+	if !skipping() || backlogOpen || PAUSED {
+		return
+	}
+	if len(reply) > 0 || dialogueIndex >= len(Script.Elements)-1 {
+		stopSkip()
+		return
+	}
+	advance, stop := skipState.Next(*flagSkipUnread)
+	if stop {
+		stopSkip()
+		return
+	}
+	// a delay, fade or animation is still finishing, it will move the script on by itself
+	if !advance || WAITING_CONFIRMATION {
+		return
+	}
+	nextDialogue(&status)
+}
+
+// playSfx plays a sound effect from the script, nothing is played while skipping
+func playSfx(s *sfx.Streamer) {
+	if skipping() {
+		return
+	}
+	s.Play(CurrentSfxVolume)
+}
+
+func readKey(element script.ScriptElement) string {
+	return element.Name + ": " + strings.Join(element.Lines, "\n")
+}
+
+// markRead records a line as read, returning false when it's the first time it's been seen
+func markRead(element script.ScriptElement) bool {
+	loadReadHistory()
+
+	lines, ok := readHistory[scriptName]
+	if !ok {
+		lines = make(map[string]bool)
+		readHistory[scriptName] = lines
+	}
+	key := readKey(element)
+	if lines[key] {
+		return true
+	}
+	lines[key] = true
+	return false
+}
+
+func loadReadHistory() {
+	if readLoaded {
+		return
+	}
+	readLoaded = true
+
+	data, err := os.ReadFile(readHistoryPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to read the read history: %v\n", err)
+		}
+		return
+	}
+
+	var history map[string][]string
+	err = json.Unmarshal(data, &history)
+	if err != nil {
+		log.Printf("read history is corrupted, starting a new one: %v\n", err)
+		return
+	}
+	for name, lines := range history {
+		readHistory[name] = make(map[string]bool)
+		for _, v := range lines {
+			readHistory[name][v] = true
+		}
+	}
+}
+
+func saveReadHistory() error {
+	if !readLoaded {
+		return nil
+	}
+
+	history := make(map[string][]string)
+	for name, lines := range readHistory {
+		for v := range lines {
+			history[name] = append(history[name], v)
+		}
+	}
+
+	data, err := json.MarshalIndent(history, "", "	")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(saveDirectory, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(readHistoryPath(), data, 0644)
+}
+
+// skipKeyCallback handles the skip keys, it needs to see key releases so it runs before anything else
+func skipKeyCallback(key glfw.Key, action glfw.Action) bool {
+	switch key {
+	case glfw.KeyLeftControl, glfw.KeyRightControl:
+		if action == glfw.Press {
+			startSkip()
+		}
+		skipHeld = action != glfw.Release
+		return true
+	case glfw.KeyF:
+		if action == glfw.Press {
+			if !SKIP {
+				startSkip()
+			}
+			SKIP = !SKIP
+		}
+		return true
+	}
+	return false
+}
+
+func drawSkip(view View) {
+	if !skipping() {
+		return
+	}
+	if skipText == nil {
+		skipText = hud.NewSolidText("SKIP >>", hud.COLOR_WHITE, Fonts[fontBold])
+	}
+	DrawText(view, skipText, 900, 28)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+// how far off the side of the screen actors slide to, far enough that the widest sprite is out of sight
+const offStage = 2
+
+// stageAction is an enter or exit action from the script, like enter_slide_left or exit_fade
+type stageAction struct {
+	enter      bool
+	transition string // fade, pop or slide
+	direction  string // the side a slide comes in from or goes out to
+}
+
+// parseStageAction reads an enter or exit action, a plain enter or exit fades
+func parseStageAction(action string) (stageAction, bool) {
+	var s stageAction
+	switch {
+	case action == "enter" || action == "exit":
+		s.enter = action == "enter"
+		s.transition = "fade"
+		return s, true
+	case strings.HasPrefix(action, "enter_"):
+		s.enter = true
+		action = strings.TrimPrefix(action, "enter_")
+	case strings.HasPrefix(action, "exit_"):
+		action = strings.TrimPrefix(action, "exit_")
+	default:
+		return s, false
+	}
+
+	switch action {
+	case "fade", "pop":
+		s.transition = action
+		return s, true
+	}
+	if strings.HasPrefix(action, "slide_") {
+		s.transition = "slide"
+		s.direction = strings.TrimPrefix(action, "slide_")
+		if _, ok := transitionDirection(s.direction); ok {
+			return s, true
+		}
+	}
+	return s, false
+}
+
+// findStageAction reads an enter or exit action, an animation in settings.json with the same name plays instead
+func findStageAction(action string) (stageAction, bool) {
+	if _, ok := ActorAnimations[action]; ok {
+		return stageAction{}, false
+	}
+	return parseStageAction(action)
+}
+
+// stageAnimation builds the animation an entrance or exit plays, the actor is put where the entrance starts from
+func stageAnimation(actor *Actor, s stageAction) script.AnimationMetadata {
+	pose := actor.GetPose()
+	frame := script.FrameMetadata{}
+	switch s.transition {
+	case "fade":
+		frame.Duration = setting(0.4)
+		if s.enter {
+			pose.Opacity = 0
+			frame.Alpha = setting(1)
+		} else {
+			frame.Alpha = setting(0)
+		}
+	case "pop":
+		frame.Duration = setting(0.35)
+		if s.enter {
+			frame.Scale = setting(pose.Scale)
+			frame.Easing = "ease_out_back"
+			pose.Scale = 0
+		} else {
+			frame.Scale = setting(0)
+			frame.Easing = "ease_in_back"
+		}
+	case "slide":
+		frame.Duration = setting(0.5)
+		dir, _ := transitionDirection(s.direction)
+		away := pose.Position.Add(dir.Mul(offStage))
+		if s.enter {
+			frame.X, frame.Y = setting(pose.Position.X()), setting(pose.Position.Y())
+			frame.Easing = "ease_out_cubic"
+			pose.Position = away
+		} else {
+			frame.X, frame.Y = setting(away.X()), setting(away.Y())
+			frame.Easing = "ease_in_cubic"
+		}
+	}
+
+	if s.enter {
+		actor.SetPose(pose)
+	}
+	// the frame has its own duration, a speed of 1 would jump straight to the end
+	return script.AnimationMetadata{Frames: []script.FrameMetadata{frame}, Track: stageTrack}
+}
+
+// enterStage puts the actor on stage with a transition, done is called once they're in place
+func enterStage(actor *Actor, s stageAction, done func()) {
+	delete(stageLeaving, actor.name)
+	charSprite[actor.name] = actor
+	updateLayout(actor)
+	actor.PlayAnimation(s.String(), stageAnimation(actor, s), done)
+}
+
+// exitStage takes the actor off stage with a transition, anyone else stays where they are.
+// Once they're gone they're put back how they were, so coming back on doesn't start from the exit
+func exitStage(actor *Actor, s stageAction, done func()) {
+	if _, ok := charSprite[actor.name]; !ok {
+		if done != nil {
+			done()
+		}
+		return
+	}
+
+	// everyone else can start moving into their new places while the actor leaves
+	stageLeaving[actor.name] = true
+	updateLayout(nil)
+
+	resting := actor.RestingPose()
+	actor.PlayAnimation(s.String(), stageAnimation(actor, s), func() {
+		delete(stageLeaving, actor.name)
+		delete(charSprite, actor.name)
+		actor.CancelAnimations()
+		actor.SetPose(resting)
+		updateLayout(nil)
+		if done != nil {
+			done()
+		}
+	})
+}
+
+func (s stageAction) String() string {
+	name := "exit_"
+	if s.enter {
+		name = "enter_"
+	}
+	if s.transition == "slide" {
+		return name + "slide_" + s.direction
+	}
+	return name + s.transition
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"log"
+	"sort"
+
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/internal/script"
+)
+
+// built-in moves get a track of their own instead of the default one,
+// so they add up with whatever animation the script is playing on the actor
+const (
+	stageTrack  = "stage"  // enter and exit transitions
+	layoutTrack = "layout" // moves into a new layout slot
+	speakTrack  = "speak"  // the bounce when someone starts speaking
+)
+
+// chained animations that finish straight away stop after this many in a row, so two that point at each other can't hang the game
+const maxInstantChain = 16
+
+// animationTrack is an animation playing on one of an actor's tracks,
+// animations on different tracks play at the same time
+type animationTrack struct {
+	name      string
+	animation string
+	metadata  script.AnimationMetadata
+	// everything the animation has moved the actor by, a stopped loop takes it back off
+	offset  actorPose
+	looper  *loop.GameLoop
+	done    func()
+	stopped bool
+	chain   int // instant animations chained before this one
+}
+
+// animationWaits is true for animations the script holds on until they end
+func animationWaits(anim script.AnimationMetadata) bool {
+	return !anim.Loop && (anim.Wait == nil || *anim.Wait)
+}
+
+// PlayAnimation starts an animation on its track, anything already playing on that track is replaced
+func (a *Actor) PlayAnimation(name string, anim script.AnimationMetadata, done func()) {
+	a.playAnimation(name, anim, done, 0)
+}
+
+func (a *Actor) playAnimation(name string, anim script.AnimationMetadata, done func(), chain int) {
+	if old, ok := a.tracks[anim.Track]; ok {
+		a.dropTrack(old)
+	}
+
+	t := &animationTrack{name: anim.Track, animation: name, metadata: anim, done: done, chain: chain}
+	a.tracks[t.name] = t
+	t.looper = AsyncAnimateActor(a, anim, &t.offset, func() {
+		a.finishTrack(t)
+	})
+}
+
+// finishTrack runs once an animation has ended, moving on to the next one in the chain if there is one
+func (a *Actor) finishTrack(t *animationTrack) {
+	// replaced or cancelled animations have already been taken off the track
+	if a.tracks[t.name] != t {
+		return
+	}
+	delete(a.tracks, t.name)
+
+	if !t.stopped && t.metadata.Next != "" {
+		next, ok := ActorAnimations[t.metadata.Next]
+		chain := 0
+		if t.looper == nil {
+			chain = t.chain + 1
+		}
+		if ok && chain < maxInstantChain {
+			// the chain keeps the track busy, so anything waiting on it waits for the whole chain
+			next.Track = t.name
+			a.playAnimation(t.metadata.Next, next, t.done, chain)
+			return
+		}
+		log.Printf("can't chain \"%s\" after \"%s\" on %s\n", t.metadata.Next, t.animation, a.name)
+	}
+
+	waiters := a.trackWaiters[t.name]
+	delete(a.trackWaiters, t.name)
+	for _, fn := range waiters {
+		fn()
+	}
+	if t.done != nil {
+		t.done()
+	}
+}
+
+// dropTrack takes an animation off its track without anything waiting on it hearing about it,
+// a loop takes back what it moved so the next animation starts from where the actor was resting
+func (a *Actor) dropTrack(t *animationTrack) {
+	delete(a.tracks, t.name)
+	t.done = nil
+	t.stopped = true
+	if t.metadata.Loop {
+		a.SetPose(subPose(a.GetPose(), t.offset))
+	}
+	if t.looper != nil {
+		t.looper.Stop()
+	}
+}
+
+// findTracks returns the tracks with the given name or playing an animation with that name, "_" matches every track
+func (a *Actor) findTracks(name string) []*animationTrack {
+	found := make([]*animationTrack, 0)
+	for k, v := range a.tracks {
+		if name == "_" || k == name || v.animation == name {
+			found = append(found, v)
+		}
+	}
+	return found
+}
+
+// StopAnimation ends the animations on a track, returns false when nothing was playing there.
+// Stopped loops put the actor back to where it rests, anything else stays where it got to
+func (a *Actor) StopAnimation(name string) bool {
+	found := a.findTracks(name)
+	for _, t := range found {
+		t.stopped = true
+		if t.metadata.Loop {
+			a.SetPose(subPose(a.GetPose(), t.offset))
+			t.offset = actorPose{}
+		}
+		if t.looper != nil {
+			t.looper.Stop()
+		} else {
+			a.finishTrack(t)
+		}
+	}
+	return len(found) > 0
+}
+
+// WaitForAnimation calls fn once the animations on a track have ended, returns false when there's nothing to wait for.
+// Loops never end on their own, so they're never waited on
+func (a *Actor) WaitForAnimation(name string, fn func()) bool {
+	tracks := make([]*animationTrack, 0)
+	for _, t := range a.findTracks(name) {
+		if !t.metadata.Loop && !t.stopped {
+			tracks = append(tracks, t)
+		}
+	}
+
+	// everything matched has to end before fn is called
+	pending := len(tracks)
+	for _, t := range tracks {
+		a.trackWaiters[t.name] = append(a.trackWaiters[t.name], func() {
+			pending--
+			if pending == 0 {
+				fn()
+			}
+		})
+	}
+	return pending > 0
+}
+
+// CancelAnimations takes every animation off the actor without finishing them,
+// the loops that were playing are returned by track so they can be started again
+func (a *Actor) CancelAnimations() map[string]string {
+	loops := make(map[string]string)
+	for _, t := range a.tracks {
+		if t.metadata.Loop {
+			loops[t.name] = t.animation
+		}
+		a.dropTrack(t)
+	}
+	a.trackWaiters = make(map[string][]func())
+	return loops
+}
+
+// Loops returns the looping animations playing on the actor by track
+func (a *Actor) Loops() map[string]string {
+	loops := make(map[string]string)
+	for _, t := range a.tracks {
+		if t.metadata.Loop {
+			loops[t.name] = t.animation
+		}
+	}
+	return loops
+}
+
+// RestingPose is the actor's pose without anything the loops playing on it have moved
+func (a *Actor) RestingPose() actorPose {
+	pose := a.GetPose()
+	for _, t := range a.tracks {
+		if t.metadata.Loop {
+			pose = subPose(pose, t.offset)
+		}
+	}
+	return pose
+}
+
+// StartLoops plays looping animations by name, the way CancelAnimations and Loops return them
+func (a *Actor) StartLoops(loops map[string]string) {
+	tracks := make([]string, 0, len(loops))
+	for k := range loops {
+		tracks = append(tracks, k)
+	}
+	sort.Strings(tracks)
+
+	for _, track := range tracks {
+		name := loops[track]
+		anim, ok := ActorAnimations[name]
+		if !ok {
+			log.Printf("can't start the \"%s\" loop on %s, it isn't in settings.json\n", name, a.name)
+			continue
+		}
+		anim.Track = track
+		a.PlayAnimation(name, anim, nil)
+	}
+}
+
+// verifyTrack checks a name given to stop or wait is a track or animation from settings.json
+func verifyTrack(name string, metadata *script.Metadata) error {
+	if name == "_" {
+		return nil
+	}
+	for k, v := range metadata.Animations {
+		if k == name || v.Track == name {
+			return nil
+		}
+	}
+	return verifyAnimation(name, metadata)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BlunterMonk/our_archive/internal/loop"
+	"github.com/BlunterMonk/our_archive/pkg/sfx"
+)
+
+// how long auto mode waits after a voice finishes before moving on, so lines don't run into each other
+const voiceAutoPause = 300 * time.Millisecond
+
+var (
+	// voice clips are kept apart from Sounds so a clip and a sound effect can share a name
+	Voices             map[string]*sfx.Streamer
+	CurrentVoiceVolume = float64(1)
+	// only one voice plays at a time, a new line cuts off the last one
+	currentVoice *sfx.Streamer
+)
+
+// playVoice plays the voice for a line in place of whatever voice is still going, nothing is played while skipping
+func playVoice(name string) {
+	stopVoice()
+	if skipping() {
+		return
+	}
+	s, ok := Voices[name]
+	if !ok {
+		return
+	}
+	s.Play(CurrentVoiceVolume)
+	currentVoice = s
+}
+
+func stopVoice() {
+	if currentVoice != nil {
+		currentVoice.Stop()
+		currentVoice = nil
+	}
+}
+
+// voicePlaying is true while the last line's voice hasn't finished
+func voicePlaying() bool {
+	return currentVoice != nil && currentVoice.IsPlaying()
+}
+
+// afterVoice calls done once the voice has finished, checking on the scene clock so recordings wait the same way
+func afterVoice(done func()) {
+	loop.New(FRAME_DURATION, func(f float64) int {
+		if voicePlaying() {
+			return 0
+		}
+		return -1
+	}, done).Start()
+}
+
+// changeVoiceVolume turns the voices up or down, the voice that's playing changes with it
+func changeVoiceVolume(by float64) {
+	CurrentVoiceVolume += by
+	if currentVoice != nil {
+		currentVoice.SetVolume(CurrentVoiceVolume)
+	}
+	fmt.Println("voice volume:", CurrentVoiceVolume)
+}